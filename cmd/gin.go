@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,11 +12,20 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+	"github.com/mahdi-cpp/upload-service/internal/tracing"
 )
 
-var Router = gin.Default()
+// Router logs through the logging middleware rather than gin's own logger.
+var Router = newRouter()
 var port = 50103
 
+func newRouter() *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery(), tracing.Middleware(), logging.Middleware())
+	return router
+}
+
 func initGin() {
 	gin.SetMode(gin.ReleaseMode)
 	Router = gin.Default()
@@ -34,9 +43,10 @@ func startServer(router *gin.Engine) {
 
 	// Run server in a goroutine
 	go func() {
-		log.Printf("Server starting on %s", srv.Addr)
+		slog.Info("server starting", "addr", srv.Addr)
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			log.Fatalf("Server failed: %v", err)
+			slog.Error("server failed", "error", err)
+			os.Exit(1)
 		}
 	}()
 
@@ -44,14 +54,15 @@ func startServer(router *gin.Engine) {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	slog.Info("shutting down server")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		slog.Error("server forced to shutdown", "error", err)
+		os.Exit(1)
 	}
 
-	log.Println("Server exited")
+	slog.Info("server exited")
 }
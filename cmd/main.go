@@ -1,58 +1,203 @@
 package main
 
 import (
+	"context"
 	"log"
+	"log/slog"
+	"os"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/api/admin"
+	assetsapi "github.com/mahdi-cpp/upload-service/internal/api/assets"
 	"github.com/mahdi-cpp/upload-service/internal/api/download"
+	"github.com/mahdi-cpp/upload-service/internal/api/health"
 	"github.com/mahdi-cpp/upload-service/internal/api/upload"
+	webhooksapi "github.com/mahdi-cpp/upload-service/internal/api/webhooks"
 	"github.com/mahdi-cpp/upload-service/internal/application"
+	"github.com/mahdi-cpp/upload-service/internal/auth"
+	"github.com/mahdi-cpp/upload-service/internal/config"
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+	"github.com/mahdi-cpp/upload-service/internal/metrics"
+	"github.com/mahdi-cpp/upload-service/internal/quota"
+	"github.com/mahdi-cpp/upload-service/internal/ratelimit"
+	"github.com/mahdi-cpp/upload-service/internal/tracing"
 )
 
 func main() {
 
+	if err := logging.Setup(); err != nil {
+		log.Fatal(err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Printf("flush traces: %v", err)
+		}
+	}()
+
 	// Load HTML templates
 	Router.LoadHTMLGlob("/app/tmp/templates/*")
 
-	// Create upload download
-	uploadHandler := &upload.Handler{
-		UploadDir: "/app/iris/com.iris.settings/uploads",
-	}
-	// Setup routes
-	setupRoutes(Router, uploadHandler)
+	// Must run before routes are registered so every route is measured.
+	Router.Use(metrics.Middleware())
 
 	newAppManager, err := application.NewAppManager()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	routMetrics(newAppManager)
+
+	go newAppManager.Trash.Run(context.Background(), config.TrashPurgeInterval)
+	go warmCaches(newAppManager)
+	go newAppManager.Events.Run(context.Background())
+	if newAppManager.Webhooks != nil {
+		incoming, _ := newAppManager.Bus.Subscribe(config.WebhookQueueSize)
+		go newAppManager.Webhooks.Run(context.Background(), incoming)
+	}
+	routHealthHandler(health.NewHealthHandler(newAppManager))
+
+	// Create upload download
+	uploadHandler := &upload.Handler{
+		UploadDir: "/app/iris/com.iris.settings/uploads",
+		Quota:     newAppManager.Quota,
+		Jobs:      newAppManager.Jobs,
+		Assets:    newAppManager.Assets,
+		Trash:     newAppManager.Trash,
+		Events:    newAppManager.Events,
+		Webhooks:  newAppManager.Webhooks,
+
+		Invalidate: newAppManager.InvalidateFiles,
+	}
+	// Setup routes
+	setupRoutes(Router, uploadHandler, newAppManager)
+
 	downloadHandler := download.NewDownloadHandler(newAppManager)
-	routDownloadHandler(downloadHandler)
+	routDownloadHandler(downloadHandler, newAppManager)
+
+	assetsHandler := assetsapi.NewAssetsHandler(newAppManager)
+	routAssetsHandler(assetsHandler, newAppManager)
+
+	webhooksHandler := webhooksapi.NewWebhooksHandler(newAppManager)
+	routWebhooksHandler(webhooksHandler, newAppManager)
+
+	adminHandler := admin.NewAdminHandler(newAppManager)
+	routAdminHandler(adminHandler, newAppManager)
 
 	startServer(Router)
 }
 
-func setupRoutes(router *gin.Engine, uploadHandler *upload.Handler) {
+func setupRoutes(router *gin.Engine, uploadHandler *upload.Handler, manager *application.AppManager) {
 	// Serve upload form
 	router.GET("/", func(c *gin.Context) {
 		c.HTML(200, "index.html", nil)
 	})
 
 	// Setup upload routes
-	routUploadHandler(router, uploadHandler)
+	routUploadHandler(router, uploadHandler, manager)
 }
 
-func routUploadHandler(router *gin.Engine, uploadHandler *upload.Handler) {
+func routUploadHandler(router *gin.Engine, uploadHandler *upload.Handler, manager *application.AppManager) {
+
+	api := router.Group("/api/v1/upload")
+	api.Use(auth.Middleware(manager.Auth), ratelimit.Middleware(manager.UploadLimiter))
 
-	router.POST("/api/v1/upload/create", uploadHandler.CreateDirectory)
-	router.POST("/api/v1/upload/media", uploadHandler.UploadMedia)
+	api.POST("create", uploadHandler.CreateDirectory)
+	api.POST("media", quota.Middleware(manager.Quota), uploadHandler.UploadMedia)
+	api.POST("commit", uploadHandler.Commit)
+	api.DELETE(":directory", uploadHandler.DeleteDirectory)
+	api.GET("usage", uploadHandler.Usage)
 }
 
-func routDownloadHandler(userHandler *download.DownloadHandler) {
+func routDownloadHandler(userHandler *download.DownloadHandler, manager *application.AppManager) {
+
+	Router.POST("/api/v1/sign", auth.Middleware(manager.Auth), userHandler.SignURL)
+
+	// Icons are shared app resources: any authenticated caller may read them.
+	icons := Router.Group("/api/v1/download/icon")
+	icons.Use(auth.Middleware(manager.Auth), ratelimit.Middleware(manager.DownloadLimiter))
+	icons.GET("*filename", userHandler.ImageIcons)
 
 	api := Router.Group("/api/v1/download")
+	api.Use(auth.Optional(manager.Auth), ratelimit.Middleware(manager.DownloadLimiter), download.Access(manager.Signer))
 
 	api.GET("original/*filename", userHandler.ImageOriginal)
 	api.GET("thumbnail/*filename", userHandler.ImageThumbnail)
-	api.GET("icon/*filename", userHandler.ImageIcons)
+	api.GET("render/*filename", userHandler.ImageRender)
+	api.GET("storyboard/*filename", userHandler.Storyboard)
+	api.GET("video/*filename", userHandler.VideoRendition)
+}
+
+func routAssetsHandler(assetsHandler *assetsapi.AssetsHandler, manager *application.AppManager) {
+
+	api := Router.Group("/api/v1/assets")
+	api.Use(auth.Middleware(manager.Auth), ratelimit.Middleware(manager.DownloadLimiter))
+
+	api.GET("", assetsHandler.List)
+	api.DELETE(":id", assetsHandler.Delete)
+	api.POST(":id/restore", assetsHandler.Restore)
+}
+
+func routWebhooksHandler(webhooksHandler *webhooksapi.WebhooksHandler, manager *application.AppManager) {
+
+	api := Router.Group("/api/v1/webhooks")
+	api.Use(auth.Middleware(manager.Auth))
+
+	api.GET("deliveries", webhooksHandler.Deliveries)
+	api.POST("deliveries/:id/retry", auth.RequireService(), webhooksHandler.Retry)
+}
+
+func routAdminHandler(adminHandler *admin.AdminHandler, manager *application.AppManager) {
+
+	api := Router.Group("/api/v1/admin")
+	api.Use(auth.Middleware(manager.Auth), auth.RequireService())
+
+	api.GET("limits", adminHandler.Limits)
+	api.GET("cache", adminHandler.Caches)
+	api.POST("cache/purge", adminHandler.PurgeCache)
+	api.POST("cache/warm", adminHandler.WarmCache)
+}
+
+func routMetrics(manager *application.AppManager) {
+
+	err := metrics.RegisterCaches(manager.Caches())
+	if err != nil {
+		log.Fatal(err)
+	}
+	metrics.RegisterJobPool(manager.Jobs)
+
+	Router.GET("/metrics", gin.WrapH(metrics.Handler()))
+}
+
+// warmCaches preloads recent thumbnails when config.CacheWarmupEnv asks for it.
+func warmCaches(manager *application.AppManager) {
+	value := os.Getenv(config.CacheWarmupEnv)
+	if value == "" {
+		return
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		slog.Warn("invalid cache warm-up count", "env", config.CacheWarmupEnv, "value", value)
+		return
+	}
+	if limit == 0 {
+		return
+	}
+
+	loaded, err := manager.WarmThumbnails(context.Background(), limit)
+	if err != nil {
+		slog.Warn("cache warm-up stopped", "loaded", loaded, "error", err)
+		return
+	}
+	slog.Info("thumbnail cache warmed", "assets", limit, "files", loaded)
+}
+
+func routHealthHandler(healthHandler *health.HealthHandler) {
+	Router.GET("/healthz", healthHandler.Liveness)
+	Router.GET("/readyz", healthHandler.Readiness)
 }
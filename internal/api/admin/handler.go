@@ -0,0 +1,117 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/application"
+	"github.com/mahdi-cpp/upload-service/internal/imagecache"
+	"github.com/mahdi-cpp/upload-service/internal/jobs"
+	"github.com/mahdi-cpp/upload-service/internal/ratelimit"
+)
+
+type AdminHandler struct {
+	manager *application.AppManager
+}
+
+func NewAdminHandler(manager *application.AppManager) *AdminHandler {
+	return &AdminHandler{
+		manager: manager,
+	}
+}
+
+type LimitsResponse struct {
+	Jobs         jobs.Stats      `json:"jobs"`
+	UploadRate   ratelimit.Stats `json:"uploadRate"`
+	DownloadRate ratelimit.Stats `json:"downloadRate"`
+}
+
+// Limits reports the occupancy of the processing pool and the rate limiters.
+func (h *AdminHandler) Limits(c *gin.Context) {
+	c.JSON(http.StatusOK, LimitsResponse{
+		Jobs:         h.manager.Jobs.Stats(),
+		UploadRate:   h.manager.UploadLimiter.Stats(),
+		DownloadRate: h.manager.DownloadLimiter.Stats(),
+	})
+}
+
+// Caches reports the counters and limits of every image cache.
+func (h *AdminHandler) Caches(c *gin.Context) {
+	stats := make(map[string]imagecache.Stats)
+	for name, cache := range h.manager.Caches() {
+		stats[name] = cache.Stats()
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// PurgeRequest selects cache entries to drop. Path and Prefix are image IDs
+// as they appear in download URLs, e.g. /com.iris.photos/users/<id>/assets.
+type PurgeRequest struct {
+	Cache  string `json:"cache"` // empty purges every cache
+	Path   string `json:"path"`
+	Prefix string `json:"prefix"`
+	All    bool   `json:"all"`
+}
+
+// PurgeCache drops cached images by exact path, by prefix or entirely and
+// reports how many entries each cache dropped.
+func (h *AdminHandler) PurgeCache(c *gin.Context) {
+
+	var request PurgeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Path == "" && request.Prefix == "" && !request.All {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "one of path, prefix or all is required"})
+		return
+	}
+
+	caches := h.manager.Caches()
+	if request.Cache != "" {
+		cache, ok := caches[request.Cache]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown cache " + request.Cache})
+			return
+		}
+		caches = map[string]*imagecache.Cache{request.Cache: cache}
+	}
+
+	purged := make(map[string]int)
+	for name, cache := range caches {
+		switch {
+		case request.All:
+			purged[name] = cache.Clear()
+		case request.Prefix != "":
+			purged[name] = cache.InvalidatePrefix(request.Prefix)
+		default:
+			if cache.Invalidate(request.Path) {
+				purged[name] = 1
+			} else {
+				purged[name] = 0
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+type WarmRequest struct {
+	Limit int `json:"limit" binding:"required,min=1,max=10000"`
+}
+
+// WarmCache loads the thumbnails of the most recently uploaded assets.
+func (h *AdminHandler) WarmCache(c *gin.Context) {
+
+	var request WarmRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loaded, err := h.manager.WarmThumbnails(c.Request.Context(), request.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "loaded": loaded})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"loaded": loaded})
+}
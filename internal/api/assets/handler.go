@@ -0,0 +1,258 @@
+package assets
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/application"
+	"github.com/mahdi-cpp/upload-service/internal/assets"
+	"github.com/mahdi-cpp/upload-service/internal/auth"
+	"github.com/mahdi-cpp/upload-service/internal/trash"
+)
+
+// MaxLimit caps the page size a client may ask for.
+const MaxLimit = 200
+
+type AssetsHandler struct {
+	manager *application.AppManager
+}
+
+func NewAssetsHandler(manager *application.AppManager) *AssetsHandler {
+	return &AssetsHandler{
+		manager: manager,
+	}
+}
+
+// http://localhost:50000/api/v1/assets?type=image&from=2025-01-01T00:00:00Z&near=35.7,51.4&radius=5000&limit=50
+
+// List returns the caller's assets matching the query string. Services may
+// list any owner's assets, or every asset when owner is omitted.
+func (h *AssetsHandler) List(c *gin.Context) {
+
+	identity, ok := auth.GetIdentity(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing identity"})
+		return
+	}
+
+	q, err := parseQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(q.Statuses) == 0 {
+		q.Statuses = assets.LiveStatuses // trash is listed with status=trashed
+	}
+
+	if !identity.IsService() {
+		if q.Owner != "" && q.Owner != identity.UserID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot list another user's assets"})
+			return
+		}
+		q.Owner = identity.UserID
+	} else if q.Owner == "" {
+		q.Owner = identity.UserID // empty for a service acting for itself: all owners
+	}
+
+	page, err := h.manager.Assets.List(q)
+	if err != nil {
+		if errors.Is(err, assets.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not list assets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// Delete moves an asset and its renditions to the trash.
+func (h *AssetsHandler) Delete(c *gin.Context) {
+	asset, ok := h.ownedAsset(c)
+	if !ok {
+		return
+	}
+
+	asset, err := h.manager.Trash.Trash(asset.ID)
+	if err != nil {
+		if errors.Is(err, trash.ErrBusy) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not delete asset"})
+		return
+	}
+
+	c.JSON(http.StatusOK, asset)
+}
+
+// Restore brings a trashed asset back to where it was deleted from.
+func (h *AssetsHandler) Restore(c *gin.Context) {
+	asset, ok := h.ownedAsset(c)
+	if !ok {
+		return
+	}
+
+	asset, err := h.manager.Trash.Restore(asset.ID)
+	if err != nil {
+		if errors.Is(err, trash.ErrNotTrashed) || errors.Is(err, trash.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not restore asset"})
+		return
+	}
+
+	c.JSON(http.StatusOK, asset)
+}
+
+// ownedAsset loads the asset named by the :id parameter, answering 404 unless
+// the caller owns it or is a service.
+func (h *AssetsHandler) ownedAsset(c *gin.Context) (*assets.Asset, bool) {
+	identity, ok := auth.GetIdentity(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing identity"})
+		return nil, false
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid asset id"})
+		return nil, false
+	}
+
+	asset, err := h.manager.Assets.Get(id)
+	if err != nil || (!identity.IsService() && asset.Owner != identity.UserID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": assets.ErrNotFound.Error()})
+		return nil, false
+	}
+	return asset, true
+}
+
+func parseQuery(c *gin.Context) (assets.Query, error) {
+	q := assets.Query{
+		Owner:     c.Query("owner"),
+		Namespace: c.Query("namespace"),
+		Make:      c.Query("make"),
+		Model:     c.Query("model"),
+		Cursor:    c.Query("cursor"),
+		Limit:     assets.DefaultLimit,
+	}
+
+	switch t := assets.MediaType(c.Query("type")); t {
+	case "", assets.MediaImage, assets.MediaVideo, assets.MediaDocument, assets.MediaAudio:
+		q.MediaType = t
+	default:
+		return q, fmt.Errorf("unsupported type %q", t)
+	}
+
+	if status := c.Query("status"); status != "" {
+		for _, s := range strings.Split(status, ",") {
+			q.Statuses = append(q.Statuses, assets.Status(strings.TrimSpace(s)))
+		}
+	}
+
+	var err error
+	if q.From, err = parseTime(c.Query("from")); err != nil {
+		return q, fmt.Errorf("from: %w", err)
+	}
+	if q.To, err = parseTime(c.Query("to")); err != nil {
+		return q, fmt.Errorf("to: %w", err)
+	}
+
+	if bbox := c.Query("bbox"); bbox != "" {
+		values, err := parseFloats(bbox, 4)
+		if err != nil {
+			return q, fmt.Errorf("bbox: %w", err)
+		}
+		// GeoJSON order: west, south, east, north.
+		q.Box = &assets.BoundingBox{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+	}
+
+	if near := c.Query("near"); near != "" {
+		values, err := parseFloats(near, 2)
+		if err != nil {
+			return q, fmt.Errorf("near: %w", err)
+		}
+		radius, err := strconv.ParseFloat(c.Query("radius"), 64)
+		if err != nil || radius <= 0 {
+			return q, errors.New("near needs a positive radius in metres")
+		}
+		q.Near = &assets.Circle{Lat: values[0], Lon: values[1], Radius: radius}
+	}
+
+	if q.MinWidth, err = parseInt(c.Query("minWidth")); err != nil {
+		return q, fmt.Errorf("minWidth: %w", err)
+	}
+	if q.MinHeight, err = parseInt(c.Query("minHeight")); err != nil {
+		return q, fmt.Errorf("minHeight: %w", err)
+	}
+
+	switch sort := assets.SortField(c.Query("sort")); sort {
+	case "", assets.SortUploaded, assets.SortCaptured:
+		q.Sort = sort
+	default:
+		return q, fmt.Errorf("unsupported sort %q", sort)
+	}
+
+	switch order := c.Query("order"); order {
+	case "", "desc":
+	case "asc":
+		q.Ascending = true
+	default:
+		return q, fmt.Errorf("unsupported order %q", order)
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil || q.Limit < 1 || q.Limit > MaxLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+		}
+	}
+
+	return q, nil
+}
+
+// parseTime accepts RFC 3339 timestamps or plain dates.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+func parseFloats(value string, n int) ([]float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != n {
+		return nil, fmt.Errorf("expected %d comma-separated numbers", n)
+	}
+	values := make([]float64, n)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", part)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func parseInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	return n, nil
+}
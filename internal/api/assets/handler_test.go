@@ -0,0 +1,48 @@
+package assets
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/assets"
+)
+
+func queryContext(rawQuery string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/v1/assets?"+rawQuery, nil)
+	return c
+}
+
+func TestParseQuery(t *testing.T) {
+
+	q, err := parseQuery(queryContext("type=video&from=2025-01-01&bbox=51,35,52,36&near=35.7,51.4&radius=500&sort=captured&order=asc&limit=10&minWidth=1080"))
+	if err != nil {
+		t.Fatalf("parseQuery: %v", err)
+	}
+	if q.MediaType != assets.MediaVideo || q.Sort != assets.SortCaptured || !q.Ascending || q.Limit != 10 || q.MinWidth != 1080 {
+		t.Errorf("unexpected query %+v", q)
+	}
+	if q.From.Year() != 2025 || q.Box == nil || q.Box.MinLat != 35 || q.Box.MinLon != 51 || q.Near == nil || q.Near.Radius != 500 {
+		t.Errorf("unexpected ranges %+v", q)
+	}
+
+	if q, err := parseQuery(queryContext("type=audio")); err != nil || q.MediaType != assets.MediaAudio {
+		t.Errorf("type=audio: got %+v, %v", q, err)
+	}
+
+	for _, bad := range []string{
+		"type=podcast",
+		"from=yesterday",
+		"bbox=1,2,3",
+		"near=35,51",
+		"sort=size",
+		"order=up",
+		"limit=1000",
+		"minWidth=-1",
+	} {
+		if _, err := parseQuery(queryContext(bad)); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package download
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/auth"
+	"github.com/mahdi-cpp/upload-service/internal/helpers"
+	"github.com/mahdi-cpp/upload-service/internal/signing"
+)
+
+// Access lets a download through when it carries a valid signed URL, or when
+// the authenticated caller owns the requested path. It must run after
+// auth.Optional so that the caller's identity is known.
+func Access(signer *signing.Signer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		claims, err := signer.Verify(c.Request.URL.Path, c.Request.URL.Query(), time.Now())
+		switch {
+		case err == nil:
+			if claims.UserID != "" {
+				if userID, _ := helpers.GetUserID(c); userID != claims.UserID {
+					helpers.AbortWithError(c, http.StatusForbidden, "signed URL was issued to another user")
+					return
+				}
+			}
+			c.Set(signing.ClaimsKey, claims)
+			c.Next()
+			return
+		case !errors.Is(err, signing.ErrMissingSignature):
+			helpers.AbortWithError(c, http.StatusForbidden, err.Error())
+			return
+		}
+
+		identity, ok := auth.GetIdentity(c)
+		if !ok {
+			helpers.AbortWithError(c, http.StatusUnauthorized, "authentication or a signed URL is required")
+			return
+		}
+
+		// A service acting for itself may read any file.
+		if identity.IsService() && identity.UserID == "" {
+			c.Next()
+			return
+		}
+
+		if !auth.OwnsPath(identity.UserID, c.Param("filename")) {
+			helpers.AbortWithError(c, http.StatusForbidden, "you don't have permission to access this file")
+			return
+		}
+
+		c.Next()
+	}
+}
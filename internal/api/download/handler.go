@@ -2,12 +2,15 @@ package download
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mahdi-cpp/upload-service/internal/application"
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+	"github.com/mahdi-cpp/upload-service/internal/media"
+	"github.com/mahdi-cpp/upload-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type DownloadHandler struct {
@@ -21,7 +24,7 @@ func NewDownloadHandler(manager *application.AppManager) *DownloadHandler {
 }
 
 // serveImage handles common image serving logic
-func (h *DownloadHandler) serveImage(c *gin.Context, loader func(context.Context, string) ([]byte, error)) {
+func (h *DownloadHandler) serveImage(c *gin.Context, cache string, loader func(context.Context, string) ([]byte, error)) {
 
 	fullPath := c.Param("filename")
 	if fullPath == "" {
@@ -29,19 +32,30 @@ func (h *DownloadHandler) serveImage(c *gin.Context, loader func(context.Context
 		return
 	}
 
-	imageBytes, err := loader(c, fullPath)
+	ctx, end := tracing.Start(c.Request.Context(), "image_cache."+cache, attribute.String("path", fullPath))
+	imageBytes, err := loader(ctx, fullPath)
+	end(err)
 	if err != nil {
-		log.Printf("Error loading image: %v", err)
+		logging.FromContext(c.Request.Context()).Error("failed to load image", "path", fullPath, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load image"})
 		return
 	}
 
-	// Determine content type from file extension
-	ext := filepath.Ext(fullPath)
-	contentType := getContentType(ext)
+	// Prefer what the bytes are; older uploads stored HEIC under .jpg.
+	contentType := getContentType(filepath.Ext(fullPath))
+	if format := media.Detect(imageBytes).Format; format != media.Unknown {
+		contentType = format.MimeType()
+	}
 	c.Data(http.StatusOK, contentType, imageBytes)
 }
 
+// renditionPath resolves filename below the thumbnail loader's base path,
+// cleaned as the image caches do, for renditions they don't hold because
+// they aren't images. Access has already checked who may read it.
+func (h *DownloadHandler) renditionPath(filename string) string {
+	return filepath.Join(h.manager.ThumbnailImageLoader.GetLocalBasePath(), filepath.Join("/", filename))
+}
+
 // getContentType returns the appropriate MIME type for file extensions
 func getContentType(ext string) string {
 	switch ext {
@@ -53,6 +67,20 @@ func getContentType(ext string) string {
 		return "image/gif"
 	case ".svg":
 		return "image/svg+xml"
+	case ".webp":
+		return "image/webp"
+	case ".avif":
+		return "image/avif"
+	case ".heic":
+		return "image/heic"
+	case ".heif":
+		return "image/heif"
+	case ".mp4":
+		return "video/mp4"
+	case ".mov":
+		return "video/quicktime"
+	case ".vtt":
+		return "text/vtt; charset=utf-8"
 	default:
 		return "application/octet-stream"
 	}
@@ -65,7 +93,7 @@ func getContentType(ext string) string {
 
 // ImageOriginal serves original images
 func (h *DownloadHandler) ImageOriginal(c *gin.Context) {
-	h.serveImage(c, h.manager.OriginalImageLoader.LoadImage)
+	h.serveImage(c, "original", h.manager.OriginalImageLoader.LoadImage)
 }
 
 // http://localhost:50000/api/v1/download/
@@ -76,7 +104,7 @@ func (h *DownloadHandler) ImageOriginal(c *gin.Context) {
 
 // ImageThumbnail serves thumbnail images
 func (h *DownloadHandler) ImageThumbnail(c *gin.Context) {
-	h.serveImage(c, h.manager.ThumbnailImageLoader.LoadImage)
+	h.serveImage(c, "thumbnail", h.manager.ThumbnailImageLoader.LoadImage)
 }
 
 // http://localhost:50000/api/v1/download/icon
@@ -86,5 +114,5 @@ func (h *DownloadHandler) ImageThumbnail(c *gin.Context) {
 
 // ImageIcons serves icon images
 func (h *DownloadHandler) ImageIcons(c *gin.Context) {
-	h.serveImage(c, h.manager.IconImageLoader.LoadImage)
+	h.serveImage(c, "icon", h.manager.IconImageLoader.LoadImage)
 }
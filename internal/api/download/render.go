@@ -0,0 +1,270 @@
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/config"
+	"github.com/mahdi-cpp/upload-service/internal/helpers"
+	"github.com/mahdi-cpp/upload-service/internal/jobs"
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+	"github.com/mahdi-cpp/upload-service/internal/metrics"
+	"github.com/mahdi-cpp/upload-service/internal/ratelimit"
+	"github.com/mahdi-cpp/upload-service/internal/thumbnail"
+	"github.com/mahdi-cpp/upload-service/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// sourceHashes remembers the content hash of originals so that a render
+// request does not re-read the whole file when it is already cached.
+var sourceHashes sync.Map // key: path|size|mtime, value: sha256 hex
+
+// http://localhost:50000/api/v1/download/render/com.iris.photos/users/018f3a8b-1b32-729a-f7e5-5467c1b2d3e4/assets/0198c111-0f9d-74f6-ab2e-6ce665ec29c6.jpg?w=540&fit=contain
+
+// ImageRender serves a variant of an original image resized on the fly.
+func (h *DownloadHandler) ImageRender(c *gin.Context) {
+
+	fullPath := c.Param("filename")
+	if fullPath == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filename parameter is missing"})
+		return
+	}
+
+	opts, err := parseRenderOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	source := filepath.Join(h.manager.OriginalImageLoader.GetLocalBasePath(), fullPath)
+	hash, err := sourceHash(source)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
+			return
+		}
+		logging.FromContext(c.Request.Context()).Error("failed to hash render source", "path", fullPath, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load image"})
+		return
+	}
+
+	c.Header("Vary", "Accept")
+	contentType := getContentType("." + string(opts.Format))
+
+	cachePath := renderCachePath(hash, opts)
+	if data, err := os.ReadFile(cachePath); err == nil {
+		c.Data(http.StatusOK, contentType, data)
+		return
+	}
+
+	release, err := h.manager.Jobs.Acquire(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, jobs.ErrSaturated) {
+			ratelimit.AbortTooManyRequests(c, config.JobRetryAfter)
+			return
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "request cancelled"})
+		return
+	}
+	done := metrics.StartStage("render")
+	_, end := tracing.Start(c.Request.Context(), "render",
+		attribute.Int("width", opts.Width),
+		attribute.Int("height", opts.Height),
+		attribute.String("format", string(opts.Format)),
+	)
+	data, err := thumbnail.Render(source, opts)
+	end(err)
+	done(err)
+	release()
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("failed to render image", "path", fullPath, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not render image"})
+		return
+	}
+
+	if err := writeRenderCache(cachePath, data); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("failed to cache rendered image", "path", fullPath, "error", err)
+	}
+
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// parseRenderOptions reads w, h, fit, q, fmt and color from the query string and
+// rejects anything outside config.RenderSizes.
+func parseRenderOptions(c *gin.Context) (thumbnail.RenderOptions, error) {
+	opts := thumbnail.RenderOptions{
+		Fit:     thumbnail.FitContain,
+		Quality: config.RenderDefaultQuality,
+	}
+
+	var err error
+	if opts.Width, err = parseDimension(c.Query("w")); err != nil {
+		return opts, fmt.Errorf("w: %w", err)
+	}
+	if opts.Height, err = parseDimension(c.Query("h")); err != nil {
+		return opts, fmt.Errorf("h: %w", err)
+	}
+	if opts.Width == 0 && opts.Height == 0 {
+		return opts, errors.New("w or h is required")
+	}
+
+	if fit := c.Query("fit"); fit != "" {
+		switch f := thumbnail.Fit(fit); f {
+		case thumbnail.FitContain, thumbnail.FitCover, thumbnail.FitAttention, thumbnail.FitFill:
+			opts.Fit = f
+		default:
+			return opts, fmt.Errorf("unsupported fit %q", fit)
+		}
+		if opts.Fit != thumbnail.FitContain && (opts.Width == 0 || opts.Height == 0) {
+			return opts, fmt.Errorf("fit %q needs both w and h", fit)
+		}
+	}
+
+	if q := c.Query("q"); q != "" {
+		quality, err := strconv.Atoi(q)
+		if err != nil || quality < 1 || quality > 100 {
+			return opts, errors.New("q must be between 1 and 100")
+		}
+		opts.Quality = quality
+	}
+
+	format, err := negotiateFormat(c.Query("fmt"), c.GetHeader("Accept"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Format = format
+
+	color := c.Query("color")
+	if color == "" {
+		color = config.DerivativeColor
+	}
+	if opts.Color, err = thumbnail.ParseColor(color); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+func parseDimension(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	if !slices.Contains(config.RenderSizes, size) {
+		return 0, fmt.Errorf("size %d is not allowed", size)
+	}
+	return size, nil
+}
+
+// negotiateFormat honours an explicit fmt parameter, otherwise picks the
+// smallest format the client advertises in its Accept header.
+func negotiateFormat(requested, accept string) (thumbnail.Format, error) {
+	switch strings.ToLower(requested) {
+	case "":
+	case "jpg", "jpeg":
+		return thumbnail.FormatJPEG, nil
+	case "webp":
+		return thumbnail.FormatWebP, nil
+	case "avif":
+		return thumbnail.FormatAVIF, nil
+	case "png":
+		return thumbnail.FormatPNG, nil
+	default:
+		return "", fmt.Errorf("unsupported fmt %q", requested)
+	}
+
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if rejected(params) {
+			continue
+		}
+		accepted[strings.ToLower(strings.TrimSpace(mediaType))] = true
+	}
+
+	switch {
+	case accepted["image/avif"]:
+		return thumbnail.FormatAVIF, nil
+	case accepted["image/webp"]:
+		return thumbnail.FormatWebP, nil
+	default:
+		return thumbnail.FormatJPEG, nil
+	}
+}
+
+// rejected reports whether Accept parameters carry q=0.
+func rejected(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || strings.TrimSpace(name) != "q" {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return err == nil && q == 0
+	}
+	return false
+}
+
+// sourceHash returns the SHA-256 of the file at path, memoised on size and mtime.
+func sourceHash(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", os.ErrNotExist
+	}
+
+	key := fmt.Sprintf("%s|%d|%d", path, info.Size(), info.ModTime().UnixNano())
+	if hash, ok := sourceHashes.Load(key); ok {
+		return hash.(string), nil
+	}
+
+	hash, err := helpers.CreateSHA256Hash(path)
+	if err != nil {
+		return "", err
+	}
+	sourceHashes.Store(key, hash)
+	return hash, nil
+}
+
+// renderCachePath builds a cache location from the source hash and the render parameters.
+func renderCachePath(sourceHash string, opts thumbnail.RenderOptions) string {
+	params := fmt.Sprintf("%s|w=%d|h=%d|fit=%s|q=%d|fmt=%s|color=%s", sourceHash, opts.Width, opts.Height, opts.Fit, opts.Quality, opts.Format, opts.Color)
+	sum := sha256.Sum256([]byte(params))
+	key := hex.EncodeToString(sum[:])
+	return filepath.Join(config.RenderCacheDir, key[:2], key+"."+string(opts.Format))
+}
+
+func writeRenderCache(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		os.Remove(tempFile.Name())
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		os.Remove(tempFile.Name())
+		return err
+	}
+	return os.Rename(tempFile.Name(), path)
+}
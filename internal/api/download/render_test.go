@@ -0,0 +1,66 @@
+package download
+
+import (
+	"testing"
+
+	"github.com/mahdi-cpp/upload-service/internal/thumbnail"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+
+	tests := []struct {
+		requested string
+		accept    string
+		want      thumbnail.Format
+	}{
+		{"", "", thumbnail.FormatJPEG},
+		{"", "image/webp,image/*;q=0.8", thumbnail.FormatWebP},
+		{"", "image/avif,image/webp,*/*", thumbnail.FormatAVIF},
+		{"", "image/avif;q=0, image/webp", thumbnail.FormatWebP},
+		{"png", "image/avif", thumbnail.FormatPNG},
+		{"jpg", "image/webp", thumbnail.FormatJPEG},
+	}
+
+	for _, tt := range tests {
+		got, err := negotiateFormat(tt.requested, tt.accept)
+		if err != nil {
+			t.Fatalf("negotiateFormat(%q, %q): %v", tt.requested, tt.accept, err)
+		}
+		if got != tt.want {
+			t.Errorf("negotiateFormat(%q, %q) = %s, want %s", tt.requested, tt.accept, got, tt.want)
+		}
+	}
+
+	if _, err := negotiateFormat("tiff", ""); err == nil {
+		t.Errorf("expected an error for an unsupported fmt")
+	}
+}
+
+func TestParseDimension(t *testing.T) {
+
+	if size, err := parseDimension("540"); err != nil || size != 540 {
+		t.Errorf("parseDimension(540) = %d, %v", size, err)
+	}
+	if _, err := parseDimension("541"); err == nil {
+		t.Errorf("expected 541 to be rejected")
+	}
+	if size, err := parseDimension(""); err != nil || size != 0 {
+		t.Errorf("parseDimension(\"\") = %d, %v", size, err)
+	}
+}
+
+func TestRenderCachePath(t *testing.T) {
+
+	opts := thumbnail.RenderOptions{Width: 540, Fit: thumbnail.FitContain, Quality: 80, Format: thumbnail.FormatWebP}
+	a := renderCachePath("abc", opts)
+	b := renderCachePath("abd", opts)
+	opts.Quality = 70
+	c := renderCachePath("abc", opts)
+	opts.Quality = 80
+	opts.Color = thumbnail.ColorPreserve
+	d := renderCachePath("abc", opts)
+
+	if a == b || a == c || a == d {
+		t.Errorf("cache keys should differ by source hash and parameters")
+	}
+}
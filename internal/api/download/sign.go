@@ -0,0 +1,85 @@
+package download
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/auth"
+	"github.com/mahdi-cpp/upload-service/internal/config"
+	"github.com/mahdi-cpp/upload-service/internal/signing"
+)
+
+const downloadPrefix = "/api/v1/download/"
+
+type SignRequest struct {
+	Path      string `json:"path"`                // e.g. /api/v1/download/original/com.iris.messages/...
+	ExpiresIn int    `json:"expiresIn,omitempty"` // seconds, defaults to config.SignedURLDefaultTTL
+	UserID    string `json:"userId,omitempty"`
+	Transform string `json:"transform,omitempty"` // e.g. w=540&fit=cover, render route only
+}
+
+type SignResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SignURL issues a signed, expiring URL for a download route.
+// It must run behind auth.Middleware.
+func (h *DownloadHandler) SignURL(c *gin.Context) {
+
+	var request SignRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON body"})
+		return
+	}
+
+	if !strings.HasPrefix(request.Path, downloadPrefix) || strings.Contains(request.Path, "..") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path must be a download route"})
+		return
+	}
+
+	// Users may only share their own files; services may sign any path.
+	identity, ok := auth.GetIdentity(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	_, filename, _ := strings.Cut(strings.TrimPrefix(request.Path, downloadPrefix), "/")
+	if !identity.IsService() && !auth.OwnsPath(identity.UserID, filename) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you don't have permission to share this file"})
+		return
+	}
+
+	ttl := config.SignedURLDefaultTTL
+	if request.ExpiresIn > 0 {
+		ttl = time.Duration(request.ExpiresIn) * time.Second
+	}
+	if ttl > config.SignedURLMaxTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expiresIn exceeds the maximum lifetime"})
+		return
+	}
+
+	claims := signing.Claims{
+		Path:      request.Path,
+		ExpiresAt: time.Now().Add(ttl).Truncate(time.Second),
+		UserID:    request.UserID,
+		Transform: request.Transform,
+	}
+
+	query := h.manager.Signer.Sign(claims)
+	if claims.Transform != "" {
+		// Put the pinned transform on the URL itself so it can be used as is.
+		transform, _ := url.ParseQuery(signing.CanonicalTransform(claims.Transform))
+		for name, values := range transform {
+			query[name] = values
+		}
+	}
+
+	c.JSON(http.StatusOK, SignResponse{
+		URL:       request.Path + "?" + query.Encode(),
+		ExpiresAt: claims.ExpiresAt,
+	})
+}
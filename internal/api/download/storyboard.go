@@ -0,0 +1,69 @@
+package download
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+	"github.com/mahdi-cpp/upload-service/internal/signing"
+)
+
+// http://localhost:50000/api/v1/download/storyboard/com.iris.photos/users/018f3a8b-1b32-729a-f7e5-5467c1b2d3e4/assets/thumbnails/0198c111-0f9d-74f6-ab2e-6ce665ec29c6_storyboard.vtt
+
+// Storyboard serves a video's WebVTT thumbnails track and the sprite sheets
+// it refers to. The track names its sheets relative to itself; when it was
+// fetched with a signed URL, each sheet gets a signed URL of its own that
+// expires with the track's.
+func (h *DownloadHandler) Storyboard(c *gin.Context) {
+
+	fullPath := c.Param("filename")
+	if !strings.Contains(filepath.Base(fullPath), "_storyboard") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not a storyboard file"})
+		return
+	}
+	if filepath.Ext(fullPath) != ".vtt" {
+		h.serveImage(c, "thumbnail", h.manager.ThumbnailImageLoader.LoadImage)
+		return
+	}
+
+	// The image cache only holds images; tracks are small and read each time.
+	track, err := os.ReadFile(h.renditionPath(fullPath))
+	if errors.Is(err, os.ErrNotExist) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "storyboard not found"})
+		return
+	}
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("failed to load storyboard", "path", fullPath, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load storyboard"})
+		return
+	}
+
+	if claims, ok := signing.GetClaims(c); ok {
+		track = signCues(track, path.Dir(c.Request.URL.Path), func(sheet string) url.Values {
+			return h.manager.Signer.Sign(signing.Claims{Path: sheet, ExpiresAt: claims.ExpiresAt, UserID: claims.UserID})
+		})
+	}
+	c.Data(http.StatusOK, getContentType(".vtt"), track)
+}
+
+// signCues rewrites the sheet names in a storyboard track, "name.jpg#xywh=...",
+// as dir/name.jpg with the query sign returns for that path.
+func signCues(track []byte, dir string, sign func(path string) url.Values) []byte {
+	lines := bytes.Split(track, []byte("\n"))
+	for i, line := range lines {
+		name, fragment, found := bytes.Cut(line, []byte("#xywh="))
+		if !found || bytes.ContainsAny(name, "/?") {
+			continue
+		}
+		sheet := path.Join(dir, string(name))
+		lines[i] = []byte(sheet + "?" + sign(sheet).Encode() + "#xywh=" + string(fragment))
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
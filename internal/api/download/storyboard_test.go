@@ -0,0 +1,90 @@
+package download
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/application"
+	"github.com/mahdi-cpp/upload-service/internal/imagecache"
+	"github.com/mahdi-cpp/upload-service/internal/signing"
+)
+
+func TestSignCues(t *testing.T) {
+
+	track := "WEBVTT\n\n00:00:00.000 --> 00:00:02.000\nabc_storyboard_0.jpg#xywh=0,0,160,90\n"
+	dir := "/api/v1/download/storyboard/com.iris.photos/users/u/assets/thumbnails"
+
+	signed := string(signCues([]byte(track), dir, func(path string) url.Values {
+		return url.Values{"sig": {"s-" + strings.TrimPrefix(path, dir+"/")}}
+	}))
+
+	want := dir + "/abc_storyboard_0.jpg?sig=s-abc_storyboard_0.jpg#xywh=0,0,160,90"
+	if !strings.Contains(signed, want) {
+		t.Errorf("signed track = %q, want a cue %q", signed, want)
+	}
+	if !strings.HasPrefix(signed, "WEBVTT\n\n00:00:00.000 --> 00:00:02.000\n") {
+		t.Errorf("timings should be left alone: %q", signed)
+	}
+}
+
+func newTestDownloadHandler(t *testing.T) (*DownloadHandler, string) {
+	dir := t.TempDir()
+	signer, err := signing.NewSigner([]signing.Key{{ID: "k1", Secret: []byte("secret")}})
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	manager := &application.AppManager{
+		ThumbnailImageLoader: imagecache.New(dir, 10, 1<<20),
+		Signer:               signer,
+	}
+	return NewDownloadHandler(manager), dir
+}
+
+func TestStoryboardServesTrack(t *testing.T) {
+
+	h, dir := newTestDownloadHandler(t)
+	thumbs := filepath.Join(dir, "com.iris.photos", "users", "u", "assets", "thumbnails")
+	if err := os.MkdirAll(thumbs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	track := "WEBVTT\n\n00:00:00.000 --> 00:00:02.000\nabc_storyboard_0.jpg#xywh=0,0,160,90\n"
+	if err := os.WriteFile(filepath.Join(thumbs, "abc_storyboard.vtt"), []byte(track), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	signed := false
+	router := gin.New()
+	router.GET("/api/v1/download/storyboard/*filename", func(c *gin.Context) {
+		if signed {
+			c.Set(signing.ClaimsKey, &signing.Claims{ExpiresAt: time.Now().Add(time.Hour)})
+		}
+	}, h.Storyboard)
+	trackURL := "/api/v1/download/storyboard/com.iris.photos/users/u/assets/thumbnails/abc_storyboard.vtt"
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, trackURL, nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/vtt") || w.Body.String() != track {
+		t.Fatalf("GET track = %d %q: %q", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	// Fetched with a signed URL, the sheets are signed too.
+	signed = true
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, trackURL, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "/thumbnails/abc_storyboard_0.jpg?") {
+		t.Errorf("signed GET track = %d: %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, strings.Replace(trackURL, "abc_", "missing_", 1), nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing track = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,33 @@
+package download
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// http://localhost:50000/api/v1/download/video/com.iris.photos/users/018f3a8b-1b32-729a-f7e5-5467c1b2d3e4/assets/thumbnails/0198c111-0f9d-74f6-ab2e-6ce665ec29c6_preview.mp4
+
+// VideoRendition serves video renditions, such as the preview clips shown on
+// gallery tiles and Live Photo motion videos, straight from disk so that
+// players can seek with range requests.
+func (h *DownloadHandler) VideoRendition(c *gin.Context) {
+
+	fullPath := c.Param("filename")
+	ext := filepath.Ext(fullPath)
+	if ext != ".mp4" && ext != ".mov" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not a video rendition"})
+		return
+	}
+
+	path := h.renditionPath(fullPath)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "video not found"})
+		return
+	}
+
+	c.Header("Content-Type", getContentType(ext))
+	c.File(path)
+}
@@ -0,0 +1,51 @@
+package download
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestVideoRendition(t *testing.T) {
+
+	h, dir := newTestDownloadHandler(t)
+	thumbs := filepath.Join(dir, "com.iris.photos", "users", "u", "assets", "thumbnails")
+	if err := os.MkdirAll(thumbs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	clip := []byte("\x00\x00\x00\x18ftypisom-preview-clip")
+	if err := os.WriteFile(filepath.Join(thumbs, "abc_preview.mp4"), clip, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.GET("/api/v1/download/video/*filename", h.VideoRendition)
+	base := "/api/v1/download/video/com.iris.photos/users/u/assets/thumbnails/"
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, base+"abc_preview.mp4", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "video/mp4" || w.Body.String() != string(clip) {
+		t.Fatalf("GET clip = %d %q: %q", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	// Players fetch clips in ranges.
+	r := httptest.NewRequest(http.MethodGet, base+"abc_preview.mp4", nil)
+	r.Header.Set("Range", "bytes=4-7")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "ftyp" {
+		t.Errorf("ranged GET = %d: %q", w.Code, w.Body.String())
+	}
+
+	for _, name := range []string{"missing_preview.mp4", "abc_270.jpg"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, base+name, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET %s = %d, want 404", name, w.Code)
+		}
+	}
+}
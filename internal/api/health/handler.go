@@ -0,0 +1,47 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/application"
+	"github.com/mahdi-cpp/upload-service/internal/config"
+	"github.com/mahdi-cpp/upload-service/internal/health"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+// NewHealthHandler registers the checks the service needs to accept uploads.
+func NewHealthHandler(manager *application.AppManager) *HealthHandler {
+	checker := health.NewChecker(config.HealthCheckTimeout, config.HealthCheckCacheTTL)
+
+	checker.Register("ffmpeg", true, health.Binary("ffmpeg", "-version"))
+	checker.Register("ffprobe", true, health.Binary("ffprobe", "-version"))
+	checker.Register("exiftool", true, health.Binary("exiftool", "-ver"))
+	checker.Register("libvips", true, health.Vips("thumbnail", "jpegload", "jpegsave"))
+	checker.Register("libvips_formats", false, health.Vips("pngsave", "webpsave", "heifload", "heifsave"))
+	checker.Register("pdf", false, health.PDF())
+	checker.Register("upload_dir", true, health.Directory(config.UploadDir, config.MinFreeDiskBytes))
+	checker.Register("storage_dir", true, health.Directory(config.StorageDir, config.MinFreeDiskBytes))
+	checker.Register("jobs", true, health.JobPool(manager.Jobs))
+
+	return &HealthHandler{checker: checker}
+}
+
+// Liveness answers as long as the process can serve HTTP.
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Readiness runs the dependency checks and returns 503 when a critical one fails.
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	report := h.checker.Run(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status == health.StatusFail {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -1,24 +1,45 @@
 package upload
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/goccy/go-json"
 	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/assets"
+	"github.com/mahdi-cpp/upload-service/internal/auth"
 	"github.com/mahdi-cpp/upload-service/internal/config"
+	"github.com/mahdi-cpp/upload-service/internal/events"
 	"github.com/mahdi-cpp/upload-service/internal/exiftool"
 	"github.com/mahdi-cpp/upload-service/internal/ffmpeg"
 	"github.com/mahdi-cpp/upload-service/internal/helpers"
+	"github.com/mahdi-cpp/upload-service/internal/jobs"
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+	"github.com/mahdi-cpp/upload-service/internal/media"
+	"github.com/mahdi-cpp/upload-service/internal/metrics"
+	"github.com/mahdi-cpp/upload-service/internal/quota"
 	"github.com/mahdi-cpp/upload-service/internal/thumbnail"
+	"github.com/mahdi-cpp/upload-service/internal/trash"
+	"github.com/mahdi-cpp/upload-service/internal/waveform"
+	"github.com/mahdi-cpp/upload-service/internal/webhooks"
 )
 
 func (h *Handler) CreateDirectory(c *gin.Context) {
 
+	userID, ok := helpers.GetUserID(c)
+	if !ok {
+		responseHelper.SendError(c, http.StatusUnauthorized, "Missing user", nil)
+		return
+	}
+
 	// Generate unique workDir
 	directoryId, err := helpers.GenerateUUID()
 	if err != nil {
@@ -26,7 +47,7 @@ func (h *Handler) CreateDirectory(c *gin.Context) {
 		return
 	}
 
-	workDir := filepath.Join(config.UploadDir, directoryId.String())
+	workDir := filepath.Join(config.UploadDir, userID, directoryId.String())
 	if err := os.MkdirAll(workDir, 0755); err != nil {
 		responseHelper.SendError(c, http.StatusForbidden, "Failed to create directory", err)
 		return
@@ -36,8 +57,62 @@ func (h *Handler) CreateDirectory(c *gin.Context) {
 	return
 }
 
+// DeleteDirectory moves every asset still in an upload directory to the
+// trash and removes the directory once it is empty.
+func (h *Handler) DeleteDirectory(c *gin.Context) {
+
+	userID, ok := helpers.GetUserID(c)
+	if !ok {
+		responseHelper.SendError(c, http.StatusUnauthorized, "Missing user", nil)
+		return
+	}
+
+	directoryID, err := uuid.Parse(c.Param("directory"))
+	if err != nil {
+		responseHelper.SendError(c, http.StatusBadRequest, "Invalid directory ID", err)
+		return
+	}
+
+	trashed, err := h.Trash.TrashDirectory(userID, directoryID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, trash.ErrBusy) {
+			status = http.StatusConflict
+		}
+		responseHelper.SendError(c, status, "Failed to delete directory", err)
+		return
+	}
+
+	workDir := filepath.Join(config.UploadDir, userID, directoryID.String())
+	if err := os.Remove(workDir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logging.FromContext(c.Request.Context()).Warn("upload directory not removed", "dir", workDir, "error", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"trashed": trashed})
+}
+
 func (h *Handler) UploadMedia(c *gin.Context) {
 
+	userID, ok := helpers.GetUserID(c)
+	if !ok {
+		responseHelper.SendError(c, http.StatusUnauthorized, "Missing user", nil)
+		return
+	}
+
+	// Parse the form up front so an oversized body is reported as such.
+	_, done := stage(c.Request.Context(), "receive")
+	err := c.Request.ParseMultipartForm(defaultMultipartMemory)
+	done(err)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			responseHelper.SendError(c, http.StatusRequestEntityTooLarge, "Upload is too large", quota.ErrFileTooLarge)
+			return
+		}
+		responseHelper.SendError(c, http.StatusBadRequest, "Invalid multipart form", err)
+		return
+	}
+
 	// 1. Extract the JSON payload from the "data" form field.
 	jsonData := c.PostForm("metadata")
 	if jsonData == "" {
@@ -58,84 +133,815 @@ func (h *Handler) UploadMedia(c *gin.Context) {
 		return
 	}
 
+	// Documents and audio are recognised by their bytes; images and videos
+	// by what the client declared.
+	info, err := sniffUpload(file)
+	if err != nil {
+		responseHelper.SendError(c, http.StatusBadRequest, "Failed to read upload", err)
+		return
+	}
+	mediaType, kind := assets.MediaImage, quota.KindImage
+	switch {
+	case info.Format.IsDocument():
+		mediaType, kind = assets.MediaDocument, quota.KindDocument
+	case info.Format.IsAudio():
+		mediaType, kind = assets.MediaAudio, quota.KindAudio
+	case request.IsVideo:
+		mediaType, kind = assets.MediaVideo, quota.KindVideo
+	}
+
+	if err := h.Quota.CheckFileSize(kind, file.Size); err != nil {
+		responseHelper.SendError(c, http.StatusRequestEntityTooLarge, "File is too large", err)
+		return
+	}
+
+	namespace := request.Namespace
+	if namespace == "" {
+		namespace = config.DefaultNamespace
+	}
+	if !validNamespace(namespace) {
+		responseHelper.SendError(c, http.StatusBadRequest, "Invalid 'namespace' in metadata", nil)
+		return
+	}
+
+	if request.CallbackURL != "" {
+		if h.Webhooks == nil {
+			responseHelper.SendError(c, http.StatusBadRequest, "Webhooks are not configured", nil)
+			return
+		}
+		if identity, _ := auth.GetIdentity(c); identity == nil || !identity.IsService() {
+			responseHelper.SendError(c, http.StatusForbidden, "Only services may set 'callbackUrl'", nil)
+			return
+		}
+		if err := webhooks.ValidURL(request.CallbackURL); err != nil {
+			responseHelper.SendError(c, http.StatusBadRequest, "Invalid 'callbackUrl' in metadata", err)
+			return
+		}
+	}
+
+	reservation, err := h.Quota.Reserve(userID, namespace, file.Size)
+	if err != nil {
+		responseHelper.SendError(c, http.StatusInsufficientStorage, "Storage quota exceeded", err)
+		return
+	}
+	defer reservation.Cancel()
+
+	// Wait for a processing slot before anything is recorded, so a client
+	// turned away to retry leaves no asset or events behind.
+	release, err := h.Jobs.Acquire(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, jobs.ErrSaturated) {
+			c.Header("Retry-After", strconv.Itoa(int(config.JobRetryAfter.Seconds())))
+			responseHelper.SendError(c, http.StatusTooManyRequests, "Server is busy, retry later", err)
+			return
+		}
+		responseHelper.SendError(c, http.StatusServiceUnavailable, "Upload cancelled", err)
+		return
+	}
+	defer release()
+
 	// Generate unique filename
 	mediaID, err := helpers.GenerateUUID()
 	if err != nil {
 		responseHelper.SendError(c, http.StatusInternalServerError, "Failed to generate media ID", err)
 		return
 	}
+	logging.AddAttrs(c, "media_id", mediaID.String())
 
-	workDir := filepath.Join(config.UploadDir, request.Directory.String())
+	if request.Directory == uuid.Nil {
+		responseHelper.SendError(c, http.StatusBadRequest, "Missing 'directory' in metadata", nil)
+		return
+	}
+
+	// Directories are created per user; one user can't write into another's.
+	workDir := filepath.Join(config.UploadDir, userID, request.Directory.String())
 	if err := os.MkdirAll(workDir, 0755); err != nil {
 		responseHelper.SendError(c, http.StatusInternalServerError, "Failed to create directory", err)
 		return
 	}
 
-	var metadata *exiftool.Metadata
+	// Store the original under the extension of what it really is, whatever
+	// the client named it.
+	originalPath := filepath.Join(workDir, mediaID.String()+originalExt(info, file.Filename, request.IsVideo))
+	asset := &assets.Asset{
+		ID:        mediaID,
+		Owner:     userID,
+		Namespace: namespace,
+		Directory: request.Directory,
+		MediaType: mediaType,
+		Status:    assets.StatusProcessing,
+		Original:  originalPath,
+		Filename:  filepath.Base(file.Filename),
+		Callback:  request.CallbackURL,
+	}
+	if err := h.Assets.Put(asset); err != nil {
+		responseHelper.SendError(c, http.StatusInternalServerError, "Failed to record asset", err)
+		return
+	}
+	h.publish(c, events.UploadReceived, asset)
+
+	var result *processed
 
 	// Process media based on type
-	if request.IsVideo {
-		metadata, err = h.processVideo(c, file, mediaID, workDir)
+	switch mediaType {
+	case assets.MediaAudio:
+		result, err = h.processAudio(c, file, mediaID, workDir, originalPath, info)
+		if err != nil {
+			h.recordFailure(c, workDir, mediaID, err)
+			responseHelper.SendError(c, http.StatusInternalServerError, "Failed to process audio", err)
+			return
+		}
+	case assets.MediaDocument:
+		result, err = h.processDocument(c, file, mediaID, workDir, originalPath)
 		if err != nil {
+			h.recordFailure(c, workDir, mediaID, err)
+			responseHelper.SendError(c, http.StatusInternalServerError, "Failed to process document", err)
+			return
+		}
+	case assets.MediaVideo:
+		result, err = h.processVideo(c, file, mediaID, workDir, originalPath)
+		if err != nil {
+			h.recordFailure(c, workDir, mediaID, err)
 			responseHelper.SendError(c, http.StatusInternalServerError, "Failed to process video", err)
 			return
 		}
-	} else {
-		metadata, err = h.processImage(c, file, mediaID, workDir)
+	default:
+		result, err = h.processImage(c, file, mediaID, workDir, originalPath, info)
 		if err != nil {
+			h.recordFailure(c, workDir, mediaID, err)
 			responseHelper.SendError(c, http.StatusInternalServerError, "Failed to process image", err)
 			return
 		}
 	}
 
-	responseHelper.SendSuccessMetadata(c, metadata)
+	metrics.UploadBytes.WithLabelValues(string(kind)).Add(float64(file.Size))
+
+	stored := mediaFilesSize(workDir, mediaID)
+	if err := reservation.Commit(stored); err != nil {
+		logging.FromContext(c.Request.Context()).Error("failed to record storage usage", "user_id", userID, "error", err)
+	}
+
+	hash, err := helpers.CreateSHA256Hash(originalPath)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Warn("failed to hash original", "error", err)
+	}
+	asset, err = h.Assets.Update(mediaID, func(a *assets.Asset) error {
+		a.Status = assets.StatusReady
+		a.Renditions = renditionFiles(workDir, mediaID, originalPath)
+		a.SHA256 = hash
+		a.Size = stored
+		a.ApplyMetadata(result.Metadata)
+		a.Pages = result.Pages
+		a.Sequence = info.Sequence
+		if result.Motion != nil {
+			a.Motion = result.Motion
+		}
+		if info.Format.IsRaw() {
+			a.Raw = &assets.Raw{Format: string(info.Format)}
+		}
+		return nil
+	})
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("failed to update asset record", "error", err)
+	} else {
+		h.invalidate(asset.Files()...)
+		h.publish(c, events.UploadProcessed, asset)
+		if paired := h.pair(c, asset); paired != nil {
+			mediaID = paired.ID
+		}
+	}
+
+	c.Header(MediaIDHeader, mediaID.String())
+	responseHelper.SendSuccessMetadata(c, result.Metadata)
+}
+
+// pairing merges one kind of two-part upload: a Live Photo's still and
+// video, or a RAW and the JPEG the camera wrote with it.
+type pairing struct {
+	name    string
+	applies func(*assets.Asset) bool
+	partner func(*assets.Asset) (*assets.Asset, error)
+	// merge takes the asset that stays first.
+	merge func(keep, other uuid.UUID) (*assets.Asset, error)
+	keeps func(*assets.Asset) bool
+}
+
+func (h *Handler) pairings() []pairing {
+	return []pairing{
+		{
+			name: "live photo",
+			applies: func(a *assets.Asset) bool {
+				return a.Motion != nil && a.Motion.Kind == assets.MotionLive && !a.Paired()
+			},
+			partner: h.Assets.LivePartner,
+			merge:   h.Assets.PairLive,
+			keeps:   func(a *assets.Asset) bool { return a.MediaType == assets.MediaImage },
+		},
+		{
+			name: "raw+jpeg",
+			applies: func(a *assets.Asset) bool {
+				return a.MediaType == assets.MediaImage && (a.Raw == nil || a.Raw.JPEGID == nil)
+			},
+			partner: h.Assets.RawPartner,
+			merge:   h.Assets.PairRaw,
+			keeps:   func(a *assets.Asset) bool { return a.Raw != nil },
+		},
+	}
+}
+
+// pair merges asset with the other half of a Live Photo or RAW+JPEG shot
+// once both are ready, returning the merged asset, or nil when there is
+// nothing to pair yet.
+func (h *Handler) pair(c *gin.Context, asset *assets.Asset) *assets.Asset {
+	h.pairMu.Lock()
+	defer h.pairMu.Unlock()
+
+	var merged *assets.Asset
+	for _, p := range h.pairings() {
+		// The other half's upload may have finished first and merged this one.
+		current, err := h.Assets.Get(asset.ID)
+		if err != nil || !p.applies(current) {
+			continue
+		}
+		partner, err := p.partner(current)
+		if err != nil {
+			if !errors.Is(err, assets.ErrNotFound) {
+				logging.FromContext(c.Request.Context()).Error("failed to look up "+p.name+" partner", "error", err)
+			}
+			continue
+		}
+
+		keep, other := current, partner
+		if !p.keeps(current) {
+			keep, other = partner, current
+		}
+		paired, err := p.merge(keep.ID, other.ID)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to pair "+p.name, "keep_id", keep.ID.String(), "other_id", other.ID.String(), "error", err)
+			continue
+		}
+		if err := h.Quota.Merge(paired.Owner); err != nil {
+			logging.FromContext(c.Request.Context()).Error("failed to record paired files in usage", "error", err)
+		}
+		h.publish(c, events.AssetPaired, paired)
+		merged, asset = paired, paired
+	}
+	return merged
+}
+
+// invalidate calls h.Invalidate when one is configured.
+func (h *Handler) invalidate(paths ...string) {
+	if h.Invalidate != nil {
+		h.Invalidate(paths...)
+	}
+}
+
+// recordFailure marks the asset as failed so the index reflects what happened,
+// and removes the files written for it in workDir.
+func (h *Handler) recordFailure(c *gin.Context, workDir string, mediaID uuid.UUID, cause error) {
+	if err := removeMediaFiles(workDir, mediaID); err != nil {
+		logging.FromContext(c.Request.Context()).Warn("failed upload files not removed", "error", err)
+	}
+	asset, err := h.Assets.Update(mediaID, func(a *assets.Asset) error {
+		a.Status = assets.StatusFailed
+		a.Error = cause.Error()
+		return nil
+	})
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("failed to update asset record", "error", err)
+		return
+	}
+	h.publish(c, events.UploadFailed, asset)
+}
+
+// publish sends an asset event when h.Events is configured. The asset has
+// already changed, so a failure is logged rather than returned.
+func (h *Handler) publish(c *gin.Context, t events.Type, asset *assets.Asset) {
+	if h.Events == nil {
+		return
+	}
+	if err := h.Events.Publish(c.Request.Context(), events.New(t, asset)); err != nil {
+		logging.FromContext(c.Request.Context()).Error("failed to publish event", "type", t, "error", err)
+	}
+}
+
+// Commit moves a processed upload out of its upload directory into storage:
+// the original to <namespace>/<destination>/ and renditions to its
+// thumbnails/ subdirectory, where the download routes serve them.
+func (h *Handler) Commit(c *gin.Context) {
+
+	userID, ok := helpers.GetUserID(c)
+	if !ok {
+		responseHelper.SendError(c, http.StatusUnauthorized, "Missing user", nil)
+		return
+	}
+
+	var request CommitRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		responseHelper.SendError(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	logging.AddAttrs(c, "media_id", request.MediaID.String())
+
+	asset, err := h.Assets.Get(request.MediaID)
+	if err != nil || asset.Owner != userID {
+		responseHelper.SendError(c, http.StatusNotFound, "Asset not found", assets.ErrNotFound)
+		return
+	}
+	if asset.Status != assets.StatusReady {
+		responseHelper.SendError(c, http.StatusConflict, "Asset is "+string(asset.Status), nil)
+		return
+	}
+
+	destination := filepath.Clean(request.Destination)
+	if !validDestination(destination) {
+		responseHelper.SendError(c, http.StatusBadRequest, "Invalid 'destination'", nil)
+		return
+	}
+	// Users may only commit into their own directories; services (for
+	// example the messages service filing chat media) may commit anywhere.
+	identity, _ := auth.GetIdentity(c)
+	if (identity == nil || !identity.IsService()) && !auth.OwnsPath(userID, destination) {
+		responseHelper.SendError(c, http.StatusForbidden, "Destination is not yours", nil)
+		return
+	}
+
+	targetDir := filepath.Join(config.StorageDir, asset.Namespace, destination)
+	moved, err := moveAsset(asset, targetDir)
+	if err != nil {
+		responseHelper.SendError(c, http.StatusInternalServerError, "Failed to move files", err)
+		return
+	}
+
+	// Both ends may be cached: the upload directory by an earlier preview,
+	// the destination by a file the move just replaced.
+	h.invalidate(append(asset.Files(), moved.Files()...)...)
+
+	asset, err = h.Assets.Update(asset.ID, func(a *assets.Asset) error {
+		a.Original = moved.Original
+		a.Renditions = moved.Renditions
+		a.Status = assets.StatusCommitted
+		return nil
+	})
+	if err != nil {
+		responseHelper.SendError(c, http.StatusInternalServerError, "Failed to update asset record", err)
+		return
+	}
+	h.publish(c, events.AssetCommitted, asset)
+
+	c.JSON(http.StatusOK, asset)
+}
+
+// Usage reports the caller's storage usage and limits.
+func (h *Handler) Usage(c *gin.Context) {
+
+	userID, ok := helpers.GetUserID(c)
+	if !ok {
+		responseHelper.SendError(c, http.StatusUnauthorized, "Missing user", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, UsageResponse{
+		Usage:     h.Quota.Usage(userID),
+		Limits:    h.Quota.Limits(),
+		Remaining: h.Quota.Remaining(userID),
+	})
 }
 
-func (h *Handler) processVideo(c *gin.Context, file *multipart.FileHeader, mediaID uuid.UUID, workDir string) (*exiftool.Metadata, error) {
+// processed is what processing learned about an upload besides its files.
+type processed struct {
+	Metadata *exiftool.Metadata
+	Pages    int            // images in a multi-image HEIF container
+	Motion   *assets.Motion // set when an embedded motion video was extracted
+}
+
+func (h *Handler) processVideo(c *gin.Context, file *multipart.FileHeader, mediaID uuid.UUID, workDir, originalVideo string) (*processed, error) {
 
-	originalVideo := filepath.Join(workDir, mediaID.String()+".mp4")
-	if err := c.SaveUploadedFile(file, originalVideo); err != nil {
+	_, done := stage(c.Request.Context(), "save")
+	err := c.SaveUploadedFile(file, originalVideo)
+	done(err)
+	if err != nil {
 		return nil, fmt.Errorf("save video: %w", err)
 	}
 
+	// exiftool's video fields vary by container; ffprobe reads the streams,
+	// and says whether the cover needs tone mapping.
+	ctx, done := stage(c.Request.Context(), "ffprobe")
+	probe, err := ffmpeg.Probe(ctx, originalVideo)
+	done(err)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Warn("failed to probe video", "error", err)
+	}
+	hdr := probe != nil && probe.Video != nil && probe.Video.HDR() != ""
+
 	coverFile := filepath.Join(workDir, mediaID.String()+".jpg")
-	if err := ffmpeg.ExtractFrame(originalVideo, coverFile); err != nil {
+	ctx, done = stage(c.Request.Context(), "ffmpeg_frame")
+	err = ffmpeg.ExtractFrame(ctx, originalVideo, coverFile, hdr)
+	done(err)
+	if err != nil {
 		return nil, fmt.Errorf("extract frame: %w", err)
 	}
 
 	sizes := []int{270, 400}
 	for _, size := range sizes {
-		thumbnailPath := filepath.Join(workDir, mediaID.String())
-		if err := thumbnail.ProcessImage2(coverFile, thumbnailPath, size); err != nil {
-			return nil, fmt.Errorf("generate thumbnail %d: %w", size, err)
+		if err := generateThumbnail(c.Request.Context(), coverFile, workDir, mediaID, size); err != nil {
+			return nil, err
 		}
 	}
 
-	return h.saveMetadata(originalVideo, mediaID, workDir)
+	metadata, err := h.saveMetadata(c.Request.Context(), originalVideo, mediaID, workDir)
+	if err != nil {
+		return nil, err
+	}
+	if probe != nil {
+		probe.Merge(&metadata.Video)
+	}
+
+	// The storyboard and preview clip only serve the gallery and player;
+	// the video plays without them.
+	if probe != nil && probe.Video != nil {
+		if err := h.storyboard(c.Request.Context(), originalVideo, workDir, mediaID, probe, hdr); err != nil {
+			logging.FromContext(c.Request.Context()).Warn("failed to make storyboard", "error", err)
+		}
+		if err := h.previewClips(c.Request.Context(), originalVideo, workDir, mediaID, probe, hdr); err != nil {
+			logging.FromContext(c.Request.Context()).Warn("failed to make preview clip", "error", err)
+		}
+	}
+	return &processed{Metadata: metadata}, nil
 }
 
-func (h *Handler) processImage(c *gin.Context, file *multipart.FileHeader, mediaID uuid.UUID, workDir string) (*exiftool.Metadata, error) {
-	original := filepath.Join(workDir, mediaID.String()+".jpg")
-	if err := c.SaveUploadedFile(file, original); err != nil {
+// previewClips makes the short muted loop shown on gallery tiles,
+// <id>_preview.mp4, and <id>_preview_animated.webp when config.PreviewClipWebP
+// is set.
+func (h *Handler) previewClips(ctx context.Context, original, workDir string, mediaID uuid.UUID, probe *ffmpeg.ProbeResult, hdr bool) error {
+	duration := time.Duration(probe.Duration * float64(time.Second))
+
+	sceneCtx, done := stage(ctx, "ffmpeg_scenes")
+	start, err := ffmpeg.InterestingStart(sceneCtx, original, duration, config.PreviewClipLength)
+	done(err)
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to find preview segment, using the start", "error", err)
+		start = 0
+	}
+
+	opts := ffmpeg.PreviewOptions{
+		Length:   config.PreviewClipLength,
+		Width:    config.PreviewClipWidth,
+		FPS:      config.PreviewClipFPS,
+		MaxBytes: config.PreviewClipMaxBytes,
+		ToneMap:  hdr,
+	}
+	outputs := []string{mediaID.String() + "_preview.mp4"}
+	if config.PreviewClipWebP {
+		outputs = append(outputs, mediaID.String()+"_preview_animated.webp")
+	}
+	for _, name := range outputs {
+		clipCtx, done := stage(ctx, "ffmpeg_preview")
+		err := ffmpeg.PreviewClip(clipCtx, original, filepath.Join(workDir, name), start, opts)
+		done(err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// storyboard samples the video into sprite sheets, <id>_storyboard_<n>.jpg,
+// and writes the WebVTT track listing them, <id>_storyboard.vtt.
+func (h *Handler) storyboard(ctx context.Context, original, workDir string, mediaID uuid.UUID, probe *ffmpeg.ProbeResult, hdr bool) (err error) {
+	// ffmpeg turns rotated video upright before filtering.
+	width, height := probe.Video.Width, probe.Video.Height
+	if probe.Video.Rotation == 90 || probe.Video.Rotation == 270 {
+		width, height = height, width
+	}
+	duration := time.Duration(probe.Duration * float64(time.Second))
+
+	prefix := filepath.Join(workDir, mediaID.String()+"_storyboard")
+	defer func() {
+		// Don't leave a partial storyboard to be taken for renditions.
+		if err != nil {
+			partial, _ := filepath.Glob(prefix + "*")
+			for _, path := range partial {
+				os.Remove(path)
+			}
+		}
+	}()
+
+	sbCtx, done := stage(ctx, "ffmpeg_storyboard")
+	sb, err := ffmpeg.MakeStoryboard(sbCtx, original, prefix, width, height, duration, ffmpeg.StoryboardOptions{
+		Interval:  config.StoryboardInterval,
+		MaxTiles:  config.StoryboardMaxTiles,
+		TileWidth: config.StoryboardTileWidth,
+		Columns:   config.StoryboardColumns,
+		Rows:      config.StoryboardRows,
+		ToneMap:   hdr,
+	})
+	done(err)
+	if err != nil {
+		return err
+	}
+
+	track, err := os.Create(prefix + ".vtt")
+	if err != nil {
+		return fmt.Errorf("storyboard track: %w", err)
+	}
+	defer track.Close()
+	if err := sb.WriteVTT(track); err != nil {
+		return fmt.Errorf("storyboard track: %w", err)
+	}
+	return track.Close()
+}
+
+// processDocument renders a PDF's first page as the cover, <id>.jpg, like a
+// video's frame, and makes the standard thumbnails from it.
+func (h *Handler) processDocument(c *gin.Context, file *multipart.FileHeader, mediaID uuid.UUID, workDir, original string) (*processed, error) {
+
+	_, done := stage(c.Request.Context(), "save")
+	err := c.SaveUploadedFile(file, original)
+	done(err)
+	if err != nil {
+		return nil, fmt.Errorf("save document: %w", err)
+	}
+
+	coverFile := filepath.Join(workDir, mediaID.String()+".jpg")
+	ctx, done := stage(c.Request.Context(), "document_cover")
+	err = thumbnail.DocumentCover(ctx, original, coverFile, config.DocumentCoverWidth, config.DisplayQuality)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := []int{270, 400}
+	for _, size := range sizes {
+		if err := generateThumbnail(c.Request.Context(), coverFile, workDir, mediaID, size); err != nil {
+			return nil, err
+		}
+	}
+
+	metadata, err := h.saveMetadata(c.Request.Context(), original, mediaID, workDir)
+	if err != nil {
+		return nil, err
+	}
+	return &processed{Metadata: metadata, Pages: metadata.Document.PageCount}, nil
+}
+
+// processAudio keeps the original as it is and adds a waveform,
+// <id>_waveform.png, thumbnails from embedded cover art when there is any,
+// and a re-encoded copy when config.AudioTranscodeCodec asks for one.
+func (h *Handler) processAudio(c *gin.Context, file *multipart.FileHeader, mediaID uuid.UUID, workDir, original string, info media.Info) (*processed, error) {
+
+	_, done := stage(c.Request.Context(), "save")
+	err := c.SaveUploadedFile(file, original)
+	done(err)
+	if err != nil {
+		return nil, fmt.Errorf("save audio: %w", err)
+	}
+
+	metadata, err := h.saveMetadata(c.Request.Context(), original, mediaID, workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cover art is a nicety; the upload is fine without it.
+	if err := h.coverArt(c.Request.Context(), original, workDir, mediaID); err != nil && !errors.Is(err, exiftool.ErrNoCoverArt) {
+		logging.FromContext(c.Request.Context()).Warn("failed to use cover art", "error", err)
+	}
+
+	if metadata.Audio.Peaks, err = h.waveform(c.Request.Context(), original, workDir, mediaID); err != nil {
+		return nil, err
+	}
+
+	codec := ffmpeg.AudioCodec(config.AudioTranscodeCodec)
+	alreadyEncoded := (codec == ffmpeg.CodecOpus && info.Format == media.Opus) ||
+		(codec == ffmpeg.CodecAAC && info.Format == media.M4A && metadata.Audio.Codec == "mp4a")
+	if codec != "" && !alreadyEncoded {
+		ext := ".ogg"
+		if codec == ffmpeg.CodecAAC {
+			ext = ".m4a"
+		}
+		out := filepath.Join(workDir, mediaID.String()+"_"+string(codec)+ext)
+		ctx, done := stage(c.Request.Context(), "transcode_audio")
+		err := ffmpeg.TranscodeAudio(ctx, original, out, codec, config.AudioTranscodeBitrate)
+		done(err)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &processed{Metadata: metadata}, nil
+}
+
+// coverArt makes the cover, <id>.jpg, and thumbnails from the picture
+// embedded in an audio file.
+func (h *Handler) coverArt(ctx context.Context, original, workDir string, mediaID uuid.UUID) error {
+	picture, err := os.CreateTemp(workDir, ".cover-*")
+	if err != nil {
+		return err
+	}
+	picture.Close()
+	defer os.Remove(picture.Name())
+
+	if err := exiftool.NewExifTool().ExtractCoverArt(ctx, original, picture.Name()); err != nil {
+		return err
+	}
+
+	coverFile := filepath.Join(workDir, mediaID.String()+".jpg")
+	coverCtx, done := stage(ctx, "cover_art")
+	_, err = thumbnail.Display(coverCtx, picture.Name(), coverFile, thumbnail.FormatJPEG, config.DisplayQuality)
+	done(err)
+	if err != nil {
+		return err
+	}
+
+	for _, size := range []int{270, 400} {
+		if err := generateThumbnail(ctx, coverFile, workDir, mediaID, size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waveform decodes the audio, reduces it to peaks and draws them as
+// <id>_waveform.png.
+func (h *Handler) waveform(ctx context.Context, original, workDir string, mediaID uuid.UUID) ([]int, error) {
+	analyzer := waveform.NewAnalyzer(config.WaveformBlock)
+	decodeCtx, done := stage(ctx, "ffmpeg_decode")
+	err := ffmpeg.DecodePCM(decodeCtx, original, config.WaveformSampleRate, analyzer)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	peaks := analyzer.Peaks(config.WaveformPeaks)
+
+	out, err := os.Create(filepath.Join(workDir, mediaID.String()+"_waveform.png"))
+	if err != nil {
+		return nil, fmt.Errorf("waveform: %w", err)
+	}
+	defer out.Close()
+	if err := waveform.WritePNG(out, peaks, config.WaveformWidth, config.WaveformHeight, config.WaveformColor); err != nil {
+		return nil, fmt.Errorf("waveform: %w", err)
+	}
+	return peaks, out.Close()
+}
+
+func (h *Handler) processImage(c *gin.Context, file *multipart.FileHeader, mediaID uuid.UUID, workDir, original string, info media.Info) (*processed, error) {
+	_, done := stage(c.Request.Context(), "save")
+	err := c.SaveUploadedFile(file, original)
+	done(err)
+	if err != nil {
 		return nil, fmt.Errorf("save image: %w", err)
 	}
 
+	result := &processed{Pages: 1}
+
+	// RAW and HEIF originals are kept as they are; clients get a display
+	// copy, which is also the quicker source for thumbnails.
+	thumbSource := original
+	switch {
+	case info.Format.IsRaw():
+		display, err := h.rawDisplay(c.Request.Context(), original, workDir, mediaID)
+		if err != nil {
+			return nil, err
+		}
+		thumbSource = display
+	case info.Format.IsHEIF():
+		format := thumbnail.Format(config.DisplayFormat)
+		display := filepath.Join(workDir, mediaID.String()+"_display."+displayExt(format))
+		ctx, done := stage(c.Request.Context(), "display")
+		displayInfo, err := thumbnail.Display(ctx, original, display, format, config.DisplayQuality)
+		done(err)
+		if err != nil {
+			return nil, fmt.Errorf("display derivative: %w", err)
+		}
+		result.Pages = displayInfo.Pages
+		thumbSource = display
+	}
+
 	sizes := []int{270}
 	for _, size := range sizes {
-		thumbnailPath := filepath.Join(workDir, mediaID.String())
-		if err := thumbnail.ProcessImage2(original, thumbnailPath, size); err != nil {
-			return nil, fmt.Errorf("generate thumbnail %d: %w", size, err)
+		if err := generateThumbnail(c.Request.Context(), thumbSource, workDir, mediaID, size); err != nil {
+			return nil, err
+		}
+	}
+
+	var animation *thumbnail.AnimationInfo
+	if info.Format.MayAnimate() {
+		if animation, err = h.animate(c.Request.Context(), original, workDir, mediaID, sizes); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Metadata, err = h.saveMetadata(c.Request.Context(), original, mediaID, workDir)
+	if err != nil {
+		return nil, err
+	}
+	if animation != nil {
+		result.Metadata.Image.FrameCount = animation.Frames
+		result.Metadata.Image.LoopCount = animation.Loop
+		result.Metadata.Image.AnimationDuration = animation.Duration.Seconds()
+	}
+
+	// Motion photos keep their video after the still; store it as its own
+	// rendition so clients needn't parse the original. The still is usable
+	// without it, so a failure is only logged.
+	if motion := result.Metadata.Motion; motion.Embedded {
+		video := filepath.Join(workDir, mediaID.String()+"_"+assets.MotionRendition+".mp4")
+		_, done := stage(c.Request.Context(), "motion_video")
+		err := media.ExtractEmbeddedVideo(original, video, motion.VideoLength)
+		done(err)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Warn("failed to extract motion photo video", "error", err)
+		} else {
+			result.Motion = &assets.Motion{Kind: assets.MotionEmbedded, PresentationTimestampUs: motion.PresentationTimestampUs}
+		}
+	}
+	return result, nil
+}
+
+// animate gives an animated image animated WebP thumbnails, <id>_animated_<size>.webp,
+// and a poster JPEG of its first frame, <id>_poster.jpg. The static JPEG
+// thumbnails are made from the first frame as for any image. It returns nil
+// for a still image.
+func (h *Handler) animate(ctx context.Context, original, workDir string, mediaID uuid.UUID, sizes []int) (*thumbnail.AnimationInfo, error) {
+	animation, err := thumbnail.Animation(original)
+	if err != nil {
+		return nil, err
+	}
+	if animation.Frames < 2 {
+		return nil, nil
+	}
+
+	limits := thumbnail.AnimationLimits{MaxFrames: config.AnimatedMaxFrames, MaxDuration: config.AnimatedMaxDuration}
+	for _, size := range sizes {
+		out := filepath.Join(workDir, fmt.Sprintf("%s_animated_%d.webp", mediaID, size))
+		sizeCtx, done := stage(ctx, "animated_"+strconv.Itoa(size))
+		err := thumbnail.AnimatedThumbnail(sizeCtx, original, out, size, limits, config.AnimatedQuality, thumbnail.Color(config.DerivativeColor))
+		done(err)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	return h.saveMetadata(original, mediaID, workDir)
+	poster := filepath.Join(workDir, mediaID.String()+"_poster.jpg")
+	posterCtx, done := stage(ctx, "poster")
+	_, err = thumbnail.Display(posterCtx, original, poster, thumbnail.FormatJPEG, config.DisplayQuality)
+	done(err)
+	if err != nil {
+		return nil, fmt.Errorf("poster: %w", err)
+	}
+	return animation, nil
+}
+
+// rawDisplay writes the display copy of a RAW original from the JPEG preview
+// the camera embedded or, without one, through libvips' own RAW loader where
+// libvips was built with libraw.
+func (h *Handler) rawDisplay(ctx context.Context, original, workDir string, mediaID uuid.UUID) (string, error) {
+	preview, err := os.CreateTemp(workDir, ".preview-*.jpg")
+	if err != nil {
+		return "", fmt.Errorf("raw preview: %w", err)
+	}
+	preview.Close()
+	defer os.Remove(preview.Name())
+
+	source := original
+	previewCtx, done := stage(ctx, "raw_preview")
+	err = exiftool.NewExifTool().ExtractPreview(previewCtx, original, preview.Name())
+	done(err)
+	if err != nil {
+		logging.FromContext(ctx).Warn("no usable raw preview, decoding the raw itself", "error", err)
+	} else {
+		source = preview.Name()
+	}
+
+	format := thumbnail.Format(config.DisplayFormat)
+	display := filepath.Join(workDir, mediaID.String()+"_display."+displayExt(format))
+	displayCtx, done := stage(ctx, "display")
+	_, err = thumbnail.Display(displayCtx, source, display, format, config.DisplayQuality)
+	done(err)
+	if err != nil {
+		return "", fmt.Errorf("display derivative: %w", err)
+	}
+	return display, nil
+}
+
+func generateThumbnail(ctx context.Context, source, workDir string, mediaID uuid.UUID, size int) error {
+	thumbnailPath := filepath.Join(workDir, mediaID.String())
+	ctx, done := stage(ctx, "thumbnail_"+strconv.Itoa(size))
+	err := thumbnail.ProcessImage2(ctx, source, thumbnailPath, size, thumbnail.Color(config.DerivativeColor))
+	done(err)
+	if err != nil {
+		return fmt.Errorf("generate thumbnail %d: %w", size, err)
+	}
+	return nil
 }
 
-func (h *Handler) saveMetadata(mediaPath string, mediaID uuid.UUID, workDir string) (*exiftool.Metadata, error) {
+func (h *Handler) saveMetadata(ctx context.Context, mediaPath string, mediaID uuid.UUID, workDir string) (*exiftool.Metadata, error) {
 	exifTool := exiftool.NewExifTool()
 	//defer exifTool.Close() // Assuming ExifTool has a Close method for cleanup
 
-	metadata, err := exifTool.GetMetadata(mediaPath)
+	ctx, done := stage(ctx, "exiftool")
+	metadata, err := exifTool.GetMetadata(ctx, mediaPath)
+	done(err)
 	if err != nil {
 		return nil, fmt.Errorf("get metadata: %w", err)
 	}
@@ -1,12 +1,106 @@
 package upload
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/assets"
+	"github.com/mahdi-cpp/upload-service/internal/media"
+	"github.com/mahdi-cpp/upload-service/internal/metrics"
+	"github.com/mahdi-cpp/upload-service/internal/thumbnail"
+	"github.com/mahdi-cpp/upload-service/internal/tracing"
 )
 
+// defaultMultipartMemory matches gin's default; larger files spill to disk.
+const defaultMultipartMemory = 32 << 20
+
+var namespacePattern = regexp.MustCompile(`^[a-z0-9]+(\.[a-z0-9_-]+)*$`)
+
+// validNamespace accepts reverse-DNS app names such as com.iris.messages.
+func validNamespace(namespace string) bool {
+	return namespacePattern.MatchString(namespace)
+}
+
+// mediaFiles lists the original and every rendition written for mediaID.
+func mediaFiles(workDir string, mediaID uuid.UUID) []string {
+	matches, _ := filepath.Glob(filepath.Join(workDir, mediaID.String()+"*"))
+	return matches
+}
+
+// mediaFilesSize adds up the original and every rendition written for mediaID.
+func mediaFilesSize(workDir string, mediaID uuid.UUID) int64 {
+	var total int64
+	for _, match := range mediaFiles(workDir, mediaID) {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// removeMediaFiles deletes what was written for mediaID before its upload
+// failed. A failed upload's reservation is cancelled, so nothing else would
+// account for these files.
+func removeMediaFiles(workDir string, mediaID uuid.UUID) error {
+	var errs []error
+	for _, match := range mediaFiles(workDir, mediaID) {
+		if err := os.RemoveAll(match); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sniffUpload detects the format of an uploaded file from its first bytes,
+// and its name for RAW formats that look like any other TIFF.
+func sniffUpload(file *multipart.FileHeader) (media.Info, error) {
+	f, err := file.Open()
+	if err != nil {
+		return media.Info{}, err
+	}
+	defer f.Close()
+	info, err := media.DetectReader(f)
+	if err != nil {
+		return media.Info{}, err
+	}
+	return media.WithName(info, file.Filename), nil
+}
+
+var extPattern = regexp.MustCompile(`^\.[a-z0-9]{1,5}$`)
+
+// originalExt picks the extension an original is stored with: the detected
+// format's, else a plausible one from the client's file name, else the
+// historical default for the media type. Documents and audio are recognised
+// by their bytes alone, so isVideo doesn't apply to them.
+func originalExt(info media.Info, filename string, isVideo bool) string {
+	if ext := info.Format.Ext(); ext != "" && (info.Format.IsDocument() || info.Format.IsAudio() || info.Format.IsImage() != isVideo) {
+		return ext
+	}
+	if ext := strings.ToLower(filepath.Ext(filename)); extPattern.MatchString(ext) {
+		return ext
+	}
+	if isVideo {
+		return ".mp4"
+	}
+	return ".jpg"
+}
+
+// displayExt is the file extension of a display derivative.
+func displayExt(format thumbnail.Format) string {
+	if format == thumbnail.FormatAVIF {
+		return "avif"
+	}
+	return "jpg"
+}
+
 // Helper functions
 func isJPEG(file *multipart.FileHeader) bool {
 	// Check content type
@@ -44,3 +138,77 @@ func getJPEGFiles(dir string) ([]string, error) {
 
 	return files, err
 }
+
+// stage times one processing step in metrics and records it as a child span
+// of ctx. Call the returned function with the step's error when it finishes.
+func stage(ctx context.Context, name string) (context.Context, func(error)) {
+	done := metrics.StartStage(name)
+	ctx, end := tracing.Start(ctx, name)
+	return ctx, func(err error) {
+		end(err)
+		done(err)
+	}
+}
+
+// renditionFiles finds what processing wrote next to original, keyed by the
+// suffix after the media ID: "270" for <id>_270.jpg, "cover" for a video's <id>.jpg.
+func renditionFiles(workDir string, mediaID uuid.UUID, original string) map[string]string {
+	matches, _ := filepath.Glob(filepath.Join(workDir, mediaID.String()+"*"))
+
+	renditions := make(map[string]string)
+	for _, match := range matches {
+		if match == original {
+			continue
+		}
+		name := strings.TrimPrefix(filepath.Base(match), mediaID.String())
+		name = strings.TrimPrefix(strings.TrimSuffix(name, filepath.Ext(name)), "_")
+		if name == "" {
+			name = "cover"
+		}
+		renditions[name] = match
+	}
+	return renditions
+}
+
+// validDestination accepts a cleaned relative directory that stays inside its namespace.
+func validDestination(destination string) bool {
+	if destination == "." || filepath.IsAbs(destination) {
+		return false
+	}
+	return !slices.Contains(strings.Split(destination, string(filepath.Separator)), "..")
+}
+
+// moveAsset renames the original into targetDir and renditions into
+// targetDir/thumbnails, returning the new paths. Files already moved are put
+// back when a later move fails, so the asset is never split across both places.
+func moveAsset(asset *assets.Asset, targetDir string) (*assets.Asset, error) {
+	thumbDir := filepath.Join(targetDir, "thumbnails")
+	if err := os.MkdirAll(thumbDir, 0755); err != nil {
+		return nil, err
+	}
+
+	moved := &assets.Asset{
+		Original:   filepath.Join(targetDir, filepath.Base(asset.Original)),
+		Renditions: make(map[string]string, len(asset.Renditions)),
+	}
+	type move struct{ from, to string }
+	moves := []move{{asset.Original, moved.Original}}
+	for name, path := range asset.Renditions {
+		moved.Renditions[name] = filepath.Join(thumbDir, filepath.Base(path))
+		moves = append(moves, move{path, moved.Renditions[name]})
+	}
+
+	for i, m := range moves {
+		err := os.ErrExist
+		if _, statErr := os.Lstat(m.to); errors.Is(statErr, os.ErrNotExist) {
+			err = os.Rename(m.from, m.to)
+		}
+		if err != nil {
+			for _, done := range moves[:i] {
+				os.Rename(done.to, done.from)
+			}
+			return nil, fmt.Errorf("move %s: %w", filepath.Base(m.from), err)
+		}
+	}
+	return moved, nil
+}
@@ -0,0 +1,129 @@
+package upload
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/assets"
+	"github.com/mahdi-cpp/upload-service/internal/media"
+)
+
+func TestRenditionFilesAndMoveAsset(t *testing.T) {
+
+	workDir := t.TempDir()
+	mediaID := uuid.New()
+	original := filepath.Join(workDir, mediaID.String()+".mp4")
+	for _, name := range []string{".mp4", ".jpg", "_270.jpg", "_400.jpg"} {
+		if err := os.WriteFile(filepath.Join(workDir, mediaID.String()+name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	renditions := renditionFiles(workDir, mediaID, original)
+	if len(renditions) != 3 || renditions["cover"] == "" || renditions["270"] == "" || renditions["400"] == "" {
+		t.Fatalf("unexpected renditions %v", renditions)
+	}
+
+	targetDir := filepath.Join(t.TempDir(), "users", "u1", "assets")
+	moved, err := moveAsset(&assets.Asset{Original: original, Renditions: renditions}, targetDir)
+	if err != nil {
+		t.Fatalf("moveAsset: %v", err)
+	}
+	if moved.Original != filepath.Join(targetDir, mediaID.String()+".mp4") {
+		t.Errorf("original moved to %s", moved.Original)
+	}
+	if moved.Renditions["270"] != filepath.Join(targetDir, "thumbnails", mediaID.String()+"_270.jpg") {
+		t.Errorf("rendition moved to %s", moved.Renditions["270"])
+	}
+	for _, path := range append([]string{moved.Original}, moved.Renditions["cover"]) {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s to exist: %v", path, err)
+		}
+	}
+}
+
+func TestRemoveMediaFiles(t *testing.T) {
+
+	workDir := t.TempDir()
+	mediaID, otherID := uuid.New(), uuid.New()
+	for _, name := range []string{mediaID.String() + ".mp4", mediaID.String() + "_270.jpg", otherID.String() + ".jpg"} {
+		if err := os.WriteFile(filepath.Join(workDir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := removeMediaFiles(workDir, mediaID); err != nil {
+		t.Fatalf("removeMediaFiles: %v", err)
+	}
+	if size := mediaFilesSize(workDir, mediaID); size != 0 {
+		t.Errorf("%d bytes left for the failed upload", size)
+	}
+	if size := mediaFilesSize(workDir, otherID); size != 1 {
+		t.Errorf("other upload's files were touched")
+	}
+}
+
+func TestMoveAssetRollsBack(t *testing.T) {
+
+	workDir := t.TempDir()
+	original := filepath.Join(workDir, "a.jpg")
+	rendition := filepath.Join(workDir, "a_270.jpg")
+	os.WriteFile(original, []byte("x"), 0644)
+	os.WriteFile(rendition, []byte("x"), 0644)
+
+	targetDir := t.TempDir()
+	os.MkdirAll(filepath.Join(targetDir, "thumbnails"), 0755)
+	os.WriteFile(filepath.Join(targetDir, "thumbnails", "a_270.jpg"), []byte("taken"), 0644)
+
+	_, err := moveAsset(&assets.Asset{Original: original, Renditions: map[string]string{"270": rendition}}, targetDir)
+	if err == nil {
+		t.Fatal("expected an error when the target exists")
+	}
+	if _, err := os.Stat(original); err != nil {
+		t.Errorf("original should have been moved back: %v", err)
+	}
+}
+
+func TestValidDestination(t *testing.T) {
+
+	for destination, want := range map[string]bool{
+		"users/u1/assets": true,
+		".":               false,
+		"/etc":            false,
+		"../other":        false,
+		"users/../../x":   false,
+	} {
+		if got := validDestination(filepath.Clean(destination)); got != want {
+			t.Errorf("validDestination(%q) = %v, want %v", destination, got, want)
+		}
+	}
+}
+
+func TestOriginalExt(t *testing.T) {
+
+	tests := []struct {
+		info     media.Info
+		filename string
+		isVideo  bool
+		want     string
+	}{
+		{media.Info{Format: media.HEIC}, "IMG_0001.jpg", false, ".heic"},
+		{media.Info{Format: media.JPEG}, "photo", false, ".jpg"},
+		{media.Info{Format: media.MOV}, "clip.mp4", true, ".mov"},
+		{media.Info{}, "scan.TIFF", false, ".tiff"},
+		{media.Info{}, "no-extension", false, ".jpg"},
+		{media.Info{}, "weird.name with space", true, ".mp4"},
+		// Declared as a video but the bytes are an image: trust neither blindly.
+		{media.Info{Format: media.PNG}, "clip.mp4", true, ".mp4"},
+		{media.Info{Format: media.PDF}, "invoice", false, ".pdf"},
+		{media.Info{Format: media.M4A}, "voice", true, ".m4a"},
+	}
+
+	for _, tt := range tests {
+		if got := originalExt(tt.info, tt.filename, tt.isVideo); got != tt.want {
+			t.Errorf("originalExt(%v, %q, %v) = %q, want %q", tt.info.Format, tt.filename, tt.isVideo, got, tt.want)
+		}
+	}
+}
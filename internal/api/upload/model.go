@@ -1,9 +1,39 @@
 package upload
 
-import "github.com/google/uuid"
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/assets"
+	"github.com/mahdi-cpp/upload-service/internal/events"
+	"github.com/mahdi-cpp/upload-service/internal/jobs"
+	"github.com/mahdi-cpp/upload-service/internal/quota"
+	"github.com/mahdi-cpp/upload-service/internal/trash"
+	"github.com/mahdi-cpp/upload-service/internal/webhooks"
+)
+
+// MediaIDHeader carries the ID of a processed upload, needed to commit it.
+// When an upload completes a Live Photo or RAW+JPEG pair it is the ID of the
+// merged asset.
+const MediaIDHeader = "X-Media-ID"
 
 type Handler struct {
 	UploadDir string
+	Quota     *quota.Tracker
+	Jobs      *jobs.Pool
+	Assets    *assets.Store
+	Trash     *trash.Bin
+	Events    events.Publisher
+
+	// Webhooks is nil when webhooks are not configured.
+	Webhooks *webhooks.Dispatcher
+
+	// Invalidate drops cached copies of files the handler writes or moves.
+	Invalidate func(paths ...string)
+
+	// pairMu serialises pairing, so two halves finishing together are
+	// merged once.
+	pairMu sync.Mutex
 }
 
 type Response struct {
@@ -22,5 +52,23 @@ type DirectoryRequest struct {
 type Request struct {
 	Directory uuid.UUID `json:"directory"`
 	IsVideo   bool      `json:"isVideo"`
+	Namespace string    `json:"namespace,omitempty"` // app namespace, e.g. com.iris.messages
+
+	// CallbackURL receives a signed POST when processing finishes or fails.
+	// Only services may set it.
+	CallbackURL string `json:"callbackUrl,omitempty"`
 	//Hash      string    `json:"hash"`
 }
+
+// CommitRequest moves a processed upload to Destination, a directory
+// relative to the asset's namespace, e.g. "users/<userID>/assets".
+type CommitRequest struct {
+	MediaID     uuid.UUID `json:"mediaId"`
+	Destination string    `json:"destination"`
+}
+
+type UsageResponse struct {
+	Usage     quota.Usage  `json:"usage"`
+	Limits    quota.Limits `json:"limits"`
+	Remaining int64        `json:"remaining"` // -1 when unlimited
+}
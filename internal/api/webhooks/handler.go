@@ -0,0 +1,108 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/application"
+	"github.com/mahdi-cpp/upload-service/internal/auth"
+	"github.com/mahdi-cpp/upload-service/internal/webhooks"
+)
+
+// MaxLimit caps how many deliveries one request may list.
+const MaxLimit = 500
+
+type WebhooksHandler struct {
+	manager *application.AppManager
+}
+
+func NewWebhooksHandler(manager *application.AppManager) *WebhooksHandler {
+	return &WebhooksHandler{
+		manager: manager,
+	}
+}
+
+// http://localhost:50000/api/v1/webhooks/deliveries?status=dead&mediaId=0198c111-0f9d-74f6-ab2e-6ce665ec29c6
+
+// Deliveries lists webhook deliveries, newest first. Users see deliveries
+// about their own assets; services see all of them.
+func (h *WebhooksHandler) Deliveries(c *gin.Context) {
+
+	identity, ok := auth.GetIdentity(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing identity"})
+		return
+	}
+	if h.manager.Webhooks == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhooks are not configured"})
+		return
+	}
+
+	filter := webhooks.Filter{Limit: 100}
+	if !identity.IsService() {
+		filter.Owner = identity.UserID
+	}
+
+	if mediaID := c.Query("mediaId"); mediaID != "" {
+		id, err := uuid.Parse(mediaID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid mediaId"})
+			return
+		}
+		filter.AssetID = id
+	}
+
+	switch status := webhooks.Status(c.Query("status")); status {
+	case "", webhooks.StatusPending, webhooks.StatusDelivered, webhooks.StatusDead:
+		filter.Status = status
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported status " + string(status)})
+		return
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(MaxLimit)})
+			return
+		}
+		filter.Limit = n
+	}
+
+	deliveries, err := h.manager.Webhooks.Store().List(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not list deliveries"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+// Retry re-queues a dead delivery. Only services may retry.
+func (h *WebhooksHandler) Retry(c *gin.Context) {
+
+	if h.manager.Webhooks == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhooks are not configured"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid delivery id"})
+		return
+	}
+
+	delivery, err := h.manager.Webhooks.Retry(id)
+	switch {
+	case errors.Is(err, webhooks.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, webhooks.ErrNotDead):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not retry delivery"})
+	default:
+		c.JSON(http.StatusOK, delivery)
+	}
+}
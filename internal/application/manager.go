@@ -1,58 +1,219 @@
 package application
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
 	"sync"
 
-	"github.com/mahdi-cpp/iris-tools/image_loader"
+	"github.com/mahdi-cpp/upload-service/internal/assets"
+	"github.com/mahdi-cpp/upload-service/internal/auth"
+	"github.com/mahdi-cpp/upload-service/internal/config"
+	"github.com/mahdi-cpp/upload-service/internal/events"
+	"github.com/mahdi-cpp/upload-service/internal/imagecache"
+	"github.com/mahdi-cpp/upload-service/internal/jobs"
+	"github.com/mahdi-cpp/upload-service/internal/media"
+	"github.com/mahdi-cpp/upload-service/internal/quota"
+	"github.com/mahdi-cpp/upload-service/internal/ratelimit"
+	"github.com/mahdi-cpp/upload-service/internal/signing"
+	"github.com/mahdi-cpp/upload-service/internal/trash"
+	"github.com/mahdi-cpp/upload-service/internal/webhooks"
+	"github.com/redis/go-redis/v9"
 )
 
 type AppManager struct {
-	mu sync.RWMutex
-	//rdb                  *redis.Client
-	IconImageLoader      *image_loader.ImageLoader
-	OriginalImageLoader  *image_loader.ImageLoader
-	ThumbnailImageLoader *image_loader.ImageLoader
+	mu                   sync.RWMutex
+	IconImageLoader      *imagecache.Cache
+	OriginalImageLoader  *imagecache.Cache
+	ThumbnailImageLoader *imagecache.Cache
+	Assets               *assets.Store
+	Bus                  *events.Memory
+	Events               *events.Outbox
+	Webhooks             *webhooks.Dispatcher // nil unless config.WebhookSecretEnv is set
+	Trash                *trash.Bin
+	Signer               *signing.Signer
+	Auth                 *auth.Authenticator
+	Quota                *quota.Tracker
+	Jobs                 *jobs.Pool
+	UploadLimiter        *ratelimit.Limiter
+	DownloadLimiter      *ratelimit.Limiter
 }
 
 func NewAppManager() (*AppManager, error) {
 
-	manager := &AppManager{
-		//rdb: redis.NewClient(&redis.Options{
-		//	Addr: "localhost:50001",
-		//	DB:   0,
-		//}),
-	}
-
-	manager.IconImageLoader = image_loader.NewImageLoader(5000, "/app/iris/", 0)
-	manager.OriginalImageLoader = image_loader.NewImageLoader(100, "", 0)
-	manager.ThumbnailImageLoader = image_loader.NewImageLoader(5000, "", 0)
-
-	//// Check the connection to Redis.
-	//_, err := manager.rdb.Ping(ctx).Result()
-	//if err != nil {
-	//	log.Fatalf("Could not connect to Redis: %v", err)
-	//}
-	//fmt.Println("Successfully connected to Redis!")
-	//
-	//// Create a new PubSub client and subscribe to a channel.
-	//pubsub := manager.Subscribe(ctx, "mychannel")
-	//
-	//// Make sure to close the subscription when the program exits.
-	//defer pubsub.Close()
-	//
-	//// Wait for confirmation that the subscription is successful.
-	//_, err = pubsub.Receive(ctx)
-	//if err != nil {
-	//	log.Fatalf("Failed to subscribe: %v", err)
-	//}
-	//
-	//fmt.Println("Subscribed to 'mychannel'. Listening for messages...")
-	//
-	//// Listen for incoming messages in a loop.
-	//ch := pubsub.Channel()
-	//for msg := range ch {
-	//	fmt.Printf("Received message from channel %s: %s\n", msg.Channel, msg.Payload)
-	//}
+	manager := &AppManager{}
+
+	manager.IconImageLoader = imagecache.New("/app/iris/", config.IconCacheEntries, config.IconCacheBytes)
+	manager.OriginalImageLoader = imagecache.New("", config.OriginalCacheEntries, config.OriginalCacheBytes)
+	manager.ThumbnailImageLoader = imagecache.New("", config.ThumbnailCacheEntries, config.ThumbnailCacheBytes)
+
+	signer, err := newSigner()
+	if err != nil {
+		return nil, err
+	}
+	manager.Signer = signer
+
+	authenticator, err := newAuthenticator()
+	if err != nil {
+		return nil, err
+	}
+	manager.Auth = authenticator
+
+	manager.Quota, err = quota.NewTracker(config.UsageFile, quota.Limits{
+		MaxFileSize: map[quota.Kind]int64{
+			quota.KindImage:    config.MaxImageSize,
+			quota.KindVideo:    config.MaxVideoSize,
+			quota.KindDocument: config.MaxDocumentSize,
+			quota.KindAudio:    config.MaxAudioSize,
+		},
+		UserQuota:       config.UserQuota,
+		NamespaceQuotas: config.NamespaceQuotas,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manager.Assets, err = assets.Open(config.AssetDBFile)
+	if err != nil {
+		return nil, err
+	}
+
+	manager.Bus = events.NewMemory()
+	manager.Events, err = events.OpenOutbox(config.EventOutboxFile, newEventTarget(manager.Bus), config.EventRetryMin, config.EventRetryMax)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret := os.Getenv(config.WebhookSecretEnv); secret != "" {
+		store, err := webhooks.OpenStore(config.WebhookDBFile)
+		if err != nil {
+			return nil, err
+		}
+		manager.Webhooks = webhooks.NewDispatcher(store, []byte(secret), manager.webhookURL, webhooks.Options{
+			Timeout:     config.WebhookTimeout,
+			MaxAttempts: config.WebhookMaxAttempts,
+			MinBackoff:  config.WebhookRetryMin,
+			MaxBackoff:  config.WebhookRetryMax,
+			Poll:        config.WebhookPollInterval,
+			Retention:   config.WebhookRetention,
+		})
+	}
+
+	manager.Trash = trash.NewBin(config.TrashDir, config.TrashRetention, manager.Assets, manager.Quota, manager.Events, manager.InvalidateFiles)
+
+	manager.Jobs = jobs.NewPool(config.MaxConcurrentJobs, config.JobQueueSize, config.JobQueueWait)
+	manager.UploadLimiter = ratelimit.NewLimiter(config.UploadRatePerSecond, config.UploadRateBurst)
+	manager.DownloadLimiter = ratelimit.NewLimiter(config.DownloadRatePerSecond, config.DownloadRateBurst)
 
 	return manager, nil
 }
+
+// Caches returns the image caches by the name the download routes use.
+func (m *AppManager) Caches() map[string]*imagecache.Cache {
+	return map[string]*imagecache.Cache{
+		"original":  m.OriginalImageLoader,
+		"thumbnail": m.ThumbnailImageLoader,
+		"icon":      m.IconImageLoader,
+	}
+}
+
+// InvalidateFiles drops cached copies of files that were moved, replaced or deleted.
+func (m *AppManager) InvalidateFiles(paths ...string) {
+	for _, cache := range m.Caches() {
+		for _, path := range paths {
+			cache.InvalidatePath(path)
+		}
+	}
+}
+
+// WarmThumbnails loads the renditions of the limit most recently uploaded
+// assets into the thumbnail cache and returns how many files were loaded.
+func (m *AppManager) WarmThumbnails(ctx context.Context, limit int) (int, error) {
+	page, err := m.Assets.List(assets.Query{
+		Statuses: []assets.Status{assets.StatusReady, assets.StatusCommitted},
+		Limit:    limit,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	loaded := 0
+	for _, asset := range page.Assets {
+		for _, path := range asset.Renditions {
+			if err := ctx.Err(); err != nil {
+				return loaded, err
+			}
+			// Preview clips and storyboard tracks are served from disk.
+			if info, err := media.DetectFile(path); err != nil || !info.Format.IsImage() {
+				continue
+			}
+			if err := m.ThumbnailImageLoader.PreloadPath(path); err != nil {
+				slog.Debug("thumbnail not preloaded", "path", path, "error", err)
+				continue
+			}
+			loaded++
+		}
+	}
+	return loaded, nil
+}
+
+// webhookURL picks the callback for an event: the URL given with the upload,
+// else the one configured for its namespace.
+func (m *AppManager) webhookURL(event events.Event) string {
+	if asset, err := m.Assets.Get(event.AssetID); err == nil && asset.Callback != "" {
+		return asset.Callback
+	}
+	return config.NamespaceWebhooks[event.Namespace]
+}
+
+// newEventTarget sends outbox events to the in-process bus and, when
+// config.EventRedisAddrEnv is set, to Redis as well.
+func newEventTarget(bus *events.Memory) events.Publisher {
+	addr := os.Getenv(config.EventRedisAddrEnv)
+	if addr == "" {
+		return bus
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	return events.Multi{bus, events.NewRedis(client, config.EventChannel, config.EventStream, config.EventStreamMaxLen)}
+}
+
+// newSigner builds the URL signer from config.SigningKeysEnv, falling back to
+// a random key so that download routes stay protected when none is configured.
+func newSigner() (*signing.Signer, error) {
+	keys, err := signing.ParseKeys(os.Getenv(config.SigningKeysEnv))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		key, err := signing.RandomKey()
+		if err != nil {
+			return nil, fmt.Errorf("generate signing key: %w", err)
+		}
+		slog.Warn("signing keys not set; signed URLs will not survive a restart", "env", config.SigningKeysEnv)
+		keys = append(keys, key)
+	}
+
+	return signing.NewSigner(keys)
+}
+
+// newAuthenticator builds the JWT and API key verifier from the config.Auth* variables.
+func newAuthenticator() (*auth.Authenticator, error) {
+	apiKeys, err := auth.ParseAPIKeys(os.Getenv(config.AuthAPIKeysEnv))
+	if err != nil {
+		return nil, err
+	}
+
+	authenticator, err := auth.NewAuthenticator(auth.Config{
+		HMACSecret: []byte(os.Getenv(config.AuthJWTSecretEnv)),
+		JWKSFile:   os.Getenv(config.AuthJWKSFileEnv),
+		APIKeys:    apiKeys,
+		Issuer:     os.Getenv(config.AuthIssuerEnv),
+		Audience:   os.Getenv(config.AuthAudienceEnv),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("auth: %w (set %s, %s or %s)", err, config.AuthJWTSecretEnv, config.AuthJWKSFileEnv, config.AuthAPIKeysEnv)
+	}
+	return authenticator, nil
+}
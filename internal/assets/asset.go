@@ -0,0 +1,111 @@
+package assets
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MediaType tells images and videos apart.
+type MediaType string
+
+const (
+	MediaImage    MediaType = "image"
+	MediaVideo    MediaType = "video"
+	MediaDocument MediaType = "document" // PDF
+	MediaAudio    MediaType = "audio"
+)
+
+// Status tracks an asset through the upload pipeline.
+type Status string
+
+const (
+	StatusProcessing Status = "processing" // received, renditions being generated
+	StatusReady      Status = "ready"      // processed, still in the upload directory
+	StatusFailed     Status = "failed"     // processing failed; see Error
+	StatusCommitted  Status = "committed"  // moved to its destination in storage
+	StatusTrashed    Status = "trashed"    // deleted, restorable until Trash.ExpiresAt
+)
+
+// LiveStatuses are every status except StatusTrashed.
+var LiveStatuses = []Status{StatusProcessing, StatusReady, StatusFailed, StatusCommitted}
+
+// Asset is the stored record for one uploaded file and its renditions.
+// Paths are absolute.
+type Asset struct {
+	ID         uuid.UUID         `json:"id"`
+	Owner      string            `json:"owner"`
+	Namespace  string            `json:"namespace"`
+	Directory  uuid.UUID         `json:"directory"`
+	MediaType  MediaType         `json:"mediaType"`
+	Status     Status            `json:"status"`
+	Error      string            `json:"error,omitempty"`
+	Original   string            `json:"original"`
+	Filename   string            `json:"filename,omitempty"`   // as named by the client
+	Renditions map[string]string `json:"renditions,omitempty"` // name, e.g. "270", to path
+	SHA256     string            `json:"sha256,omitempty"`
+	Size       int64             `json:"size"`
+	MimeType   string            `json:"mimeType,omitempty"`
+	Width      int               `json:"width,omitempty"`
+	Height     int               `json:"height,omitempty"`
+	Duration   float64           `json:"duration,omitempty"` // seconds, for audio, videos, motion photos and animations
+	Frames     int               `json:"frames,omitempty"`   // animated images only
+	Pages      int               `json:"pages,omitempty"`    // document pages, or images in a multi-image HEIF container
+	Sequence   bool              `json:"sequence,omitempty"` // HEIF image sequence, e.g. a burst
+	Motion     *Motion           `json:"motion,omitempty"`   // live or motion photo
+	Raw        *Raw              `json:"raw,omitempty"`      // camera RAW original
+	Document   *Document         `json:"document,omitempty"` // documents only
+	Audio      *Audio            `json:"audio,omitempty"`    // audio only
+	CapturedAt time.Time         `json:"capturedAt"`
+	Camera     Camera            `json:"camera"`
+	Location   *Location         `json:"location,omitempty"`
+	UploadedAt time.Time         `json:"uploadedAt"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+	Trash      *TrashInfo        `json:"trash,omitempty"`
+	Callback   string            `json:"callback,omitempty"` // webhook URL given with the upload
+}
+
+// TrashInfo records where a trashed asset came from so it can be restored.
+type TrashInfo struct {
+	TrashedAt      time.Time         `json:"trashedAt"`
+	ExpiresAt      time.Time         `json:"expiresAt"`
+	PreviousStatus Status            `json:"previousStatus"`
+	Files          map[string]string `json:"files"` // trash path to original path
+}
+
+// Document holds a document's descriptive metadata.
+type Document struct {
+	Title  string `json:"title,omitempty"`
+	Author string `json:"author,omitempty"`
+}
+
+// Audio holds an audio file's format, tags and waveform.
+type Audio struct {
+	Codec      string `json:"codec,omitempty"`
+	Bitrate    int    `json:"bitrate,omitempty"` // bits per second
+	SampleRate int    `json:"sampleRate,omitempty"`
+	Channels   int    `json:"channels,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Artist     string `json:"artist,omitempty"`
+	Album      string `json:"album,omitempty"`
+	Peaks      []int  `json:"peaks,omitempty"` // loudness from 0 to 100 across the recording
+}
+
+type Camera struct {
+	Make  string `json:"make,omitempty"`
+	Model string `json:"model,omitempty"`
+}
+
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Files lists the original and every rendition.
+func (a *Asset) Files() []string {
+	files := []string{a.Original}
+	for _, path := range a.Renditions {
+		files = append(files, path)
+	}
+	return files
+}
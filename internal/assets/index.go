@@ -0,0 +1,83 @@
+package assets
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+// SortField names the time an asset listing is ordered by.
+type SortField string
+
+const (
+	SortUploaded SortField = "uploaded"
+	SortCaptured SortField = "captured" // falls back to upload time when unknown
+)
+
+// Index keys are <owner> 0x00 <time> <id>, where time is big-endian so byte
+// order matches time order. Every asset is indexed twice: under its owner and
+// under the empty owner, which lists all assets.
+const indexKeySuffix = 8 + 16
+
+func indexBucket(sort SortField) []byte {
+	return []byte("index_" + string(sort))
+}
+
+func sortTime(asset *Asset, sort SortField) time.Time {
+	if sort == SortCaptured && !asset.CapturedAt.IsZero() {
+		return asset.CapturedAt
+	}
+	return asset.UploadedAt
+}
+
+func ownerPrefix(owner string) []byte {
+	return append([]byte(owner), 0)
+}
+
+// timeBytes encodes t so that earlier times sort first, including times before 1970.
+func timeBytes(t time.Time) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano())^(1<<63))
+}
+
+// indexPosition is the part of an index key after the owner prefix.
+func indexPosition(t time.Time, id uuid.UUID) []byte {
+	return append(timeBytes(t), id[:]...)
+}
+
+func indexKey(owner string, t time.Time, id uuid.UUID) []byte {
+	return append(ownerPrefix(owner), indexPosition(t, id)...)
+}
+
+func indexKeys(asset *Asset, sort SortField) [][]byte {
+	t := sortTime(asset, sort)
+	return [][]byte{
+		indexKey(asset.Owner, t, asset.ID),
+		indexKey("", t, asset.ID),
+	}
+}
+
+func index(tx *bolt.Tx, asset *Asset) error {
+	for _, sort := range []SortField{SortUploaded, SortCaptured} {
+		bucket := tx.Bucket(indexBucket(sort))
+		for _, key := range indexKeys(asset, sort) {
+			if err := bucket.Put(key, asset.ID[:]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func unindex(tx *bolt.Tx, asset *Asset) error {
+	for _, sort := range []SortField{SortUploaded, SortCaptured} {
+		bucket := tx.Bucket(indexBucket(sort))
+		for _, key := range indexKeys(asset, sort) {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package assets
+
+import (
+	"github.com/mahdi-cpp/upload-service/internal/exiftool"
+)
+
+// ApplyMetadata copies the indexed fields out of exiftool's metadata.
+func (a *Asset) ApplyMetadata(md *exiftool.Metadata) {
+	if md == nil {
+		return
+	}
+
+	a.MimeType = md.FileInfo.MimeType
+	a.Width, a.Height = md.Image.Width, md.Image.Height
+	if a.MediaType == MediaVideo {
+		if md.Video.Width > 0 {
+			a.Width, a.Height = md.Video.Width, md.Video.Height
+		}
+		a.Duration = md.Video.Duration
+	}
+
+	if a.MediaType == MediaAudio {
+		a.Duration = md.Audio.Duration
+		a.Audio = &Audio{
+			Codec:      md.Audio.Codec,
+			Bitrate:    md.Audio.Bitrate,
+			SampleRate: md.Audio.SampleRate,
+			Channels:   md.Audio.Channels,
+			Title:      md.Audio.Title,
+			Artist:     md.Audio.Artist,
+			Album:      md.Audio.Album,
+			Peaks:      md.Audio.Peaks,
+		}
+	}
+
+	if a.MediaType == MediaDocument {
+		a.Pages = md.Document.PageCount
+		if md.Document.Title != "" || md.Document.Author != "" {
+			a.Document = &Document{Title: md.Document.Title, Author: md.Document.Author}
+		}
+	}
+
+	if md.Image.FrameCount > 1 {
+		a.Frames = md.Image.FrameCount
+		a.Duration = md.Image.AnimationDuration
+	}
+
+	a.CapturedAt = md.DateTimeOriginal
+	a.Camera = Camera{Make: md.Camera.Make, Model: md.Camera.Model}
+
+	if md.Motion.ContentIdentifier != "" {
+		a.Motion = &Motion{Kind: MotionLive, ContentID: md.Motion.ContentIdentifier}
+	}
+
+	if md.Location.Latitude != 0 || md.Location.Longitude != 0 {
+		a.Location = &Location{Latitude: md.Location.Latitude, Longitude: md.Location.Longitude}
+	}
+}
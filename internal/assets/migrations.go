@@ -0,0 +1,69 @@
+package assets
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+// migrations run in order. The schema version stored in the meta bucket is
+// the number of migrations applied. Pending migrations share one transaction,
+// so a failure leaves the database as it was. Append new migrations; never
+// edit or reorder released ones.
+var migrations = []func(tx *bolt.Tx) error{
+	// 1: asset records keyed by ID.
+	func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(assetsBucket)
+		return err
+	},
+	// 2: time-ordered indexes for listing, backfilled from existing records.
+	func(tx *bolt.Tx) error {
+		for _, sort := range []SortField{SortUploaded, SortCaptured} {
+			if _, err := tx.CreateBucketIfNotExists(indexBucket(sort)); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(assetsBucket).ForEach(func(k, v []byte) error {
+			id, err := uuid.FromBytes(k)
+			if err != nil {
+				return err
+			}
+			asset, err := get(tx, id)
+			if err != nil {
+				return err
+			}
+			return index(tx, asset)
+		})
+	},
+}
+
+func (s *Store) migrate() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		version := schemaVersion(meta)
+		if version > len(migrations) {
+			return fmt.Errorf("assets: database schema %d is newer than this build (%d)", version, len(migrations))
+		}
+
+		for ; version < len(migrations); version++ {
+			if err := migrations[version](tx); err != nil {
+				return fmt.Errorf("assets: migration %d: %w", version+1, err)
+			}
+		}
+		return meta.Put(versionKey, binary.BigEndian.AppendUint64(nil, uint64(version)))
+	})
+}
+
+func schemaVersion(meta *bolt.Bucket) int {
+	value := meta.Get(versionKey)
+	if len(value) != 8 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(value))
+}
@@ -0,0 +1,71 @@
+package assets
+
+import (
+	"github.com/google/uuid"
+)
+
+// MotionKind tells how a motion photo's video was uploaded.
+type MotionKind string
+
+const (
+	MotionLive     MotionKind = "live"     // Apple Live Photo: still and MOV uploaded separately
+	MotionEmbedded MotionKind = "embedded" // Google or Samsung motion photo: MP4 appended to the still
+)
+
+// MotionRendition names the video of a motion photo in Asset.Renditions.
+const MotionRendition = "motion"
+
+// Motion describes a live or motion photo. Once the video is known it is the
+// MotionRendition of the still; until a Live Photo's other half is uploaded
+// both the still and the video carry the pairing ContentID.
+type Motion struct {
+	Kind      MotionKind `json:"kind"`
+	ContentID string     `json:"contentId,omitempty"` // Apple ContentIdentifier
+
+	// VideoID is the ID the Live Photo's video was uploaded under before it
+	// was merged into the still.
+	VideoID *uuid.UUID `json:"videoId,omitempty"`
+
+	// PresentationTimestampUs is the video frame the still was taken from.
+	PresentationTimestampUs int64 `json:"presentationTimestampUs,omitempty"`
+}
+
+// Paired reports whether the asset is a still with its motion video.
+func (a *Asset) Paired() bool {
+	_, ok := a.Renditions[MotionRendition]
+	return a.Motion != nil && ok
+}
+
+// LivePartner finds the other half of a Live Photo: a ready asset of the
+// other media type in the same upload directory with the same ContentID.
+// It returns ErrNotFound when it hasn't been uploaded yet.
+func (s *Store) LivePartner(asset *Asset) (*Asset, error) {
+	if asset.Motion == nil || asset.Motion.ContentID == "" {
+		return nil, ErrNotFound
+	}
+
+	mediaType := MediaVideo
+	if asset.MediaType == MediaVideo {
+		mediaType = MediaImage
+	}
+	return s.findPartner(asset, mediaType, func(candidate *Asset) bool {
+		return candidate.Motion != nil && candidate.Motion.ContentID == asset.Motion.ContentID && !candidate.Paired()
+	})
+}
+
+// PairLive merges a Live Photo's video into its still: the video's original
+// becomes the still's MotionRendition, its renditions are kept under
+// "motion_<name>", and the video's own record is removed. Both must be ready,
+// in the same upload directory, and share a ContentID.
+func (s *Store) PairLive(stillID, videoID uuid.UUID) (*Asset, error) {
+	return s.absorb(stillID, videoID, MotionRendition,
+		func(still, video *Asset) bool {
+			return still.MediaType == MediaImage && video.MediaType == MediaVideo &&
+				still.Motion != nil && video.Motion != nil && still.Motion.ContentID == video.Motion.ContentID
+		},
+		func(still, video *Asset) {
+			still.Duration = video.Duration
+			still.Motion.Kind = MotionLive
+			still.Motion.VideoID = &video.ID
+		})
+}
@@ -0,0 +1,83 @@
+package assets
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var ErrNotPairable = errors.New("assets cannot be paired")
+
+// findPartner returns the first ready asset of mediaType in asset's upload
+// directory, other than asset itself, that match accepts. It returns
+// ErrNotFound when there is none yet.
+func (s *Store) findPartner(asset *Asset, mediaType MediaType, match func(*Asset) bool) (*Asset, error) {
+	q := Query{
+		Owner:     asset.Owner,
+		Namespace: asset.Namespace,
+		Directory: asset.Directory,
+		MediaType: mediaType,
+		Statuses:  []Status{StatusReady},
+		Limit:     DefaultLimit,
+	}
+
+	for {
+		page, err := s.List(q)
+		if err != nil {
+			return nil, err
+		}
+		for _, candidate := range page.Assets {
+			if candidate.ID != asset.ID && match(candidate) {
+				return candidate, nil
+			}
+		}
+		if page.NextCursor == "" {
+			return nil, ErrNotFound
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// absorb merges other into keep in one transaction: other's original becomes
+// keep's rendition name, its renditions are kept as "<name>_<rendition>", its
+// size is added to keep's and its record is removed. Both must be ready and
+// in the same upload directory, and valid must accept them; apply then
+// records the pairing on keep.
+func (s *Store) absorb(keepID, otherID uuid.UUID, name string, valid func(keep, other *Asset) bool, apply func(keep, other *Asset)) (*Asset, error) {
+	var keep *Asset
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if keep, err = get(tx, keepID); err != nil {
+			return err
+		}
+		other, err := get(tx, otherID)
+		if err != nil {
+			return err
+		}
+
+		if _, taken := keep.Renditions[name]; taken ||
+			keep.Status != StatusReady || other.Status != StatusReady ||
+			keep.Owner != other.Owner || keep.Namespace != other.Namespace || keep.Directory != other.Directory ||
+			!valid(keep, other) {
+			return ErrNotPairable
+		}
+
+		if keep.Renditions == nil {
+			keep.Renditions = make(map[string]string)
+		}
+		keep.Renditions[name] = other.Original
+		for rendition, path := range other.Renditions {
+			keep.Renditions[name+"_"+rendition] = path
+		}
+		keep.Size += other.Size
+		apply(keep, other)
+		keep.UpdatedAt = s.now().UTC()
+
+		if err := put(tx, keep); err != nil {
+			return err
+		}
+		return del(tx, other.ID)
+	})
+	return keep, err
+}
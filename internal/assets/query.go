@@ -0,0 +1,251 @@
+package assets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// DefaultLimit is the page size when a query doesn't set one.
+const DefaultLimit = 50
+
+// Query selects assets for a listing. Zero values don't filter.
+type Query struct {
+	Owner     string // empty lists every owner
+	Namespace string
+	Directory uuid.UUID
+	MediaType MediaType
+	Statuses  []Status
+	From, To  time.Time // inclusive bounds on the Sort time
+	Make      string    // camera make, case-insensitive
+	Model     string    // camera model, case-insensitive
+	Box       *BoundingBox
+	Near      *Circle
+	MinWidth  int
+	MinHeight int
+
+	Sort      SortField // defaults to SortUploaded
+	Ascending bool      // oldest first; the default is newest first
+	Limit     int
+	Cursor    string // NextCursor of the previous page
+}
+
+// BoundingBox is a latitude/longitude rectangle.
+type BoundingBox struct {
+	MinLat, MinLon, MaxLat, MaxLon float64
+}
+
+// Circle is everything within Radius metres of a point.
+type Circle struct {
+	Lat, Lon float64
+	Radius   float64
+}
+
+// Page is one page of a listing. NextCursor is empty on the last page.
+type Page struct {
+	Assets     []*Asset `json:"assets"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
+
+// List walks the time index for q.Sort and returns the matching assets.
+// Cursors point into the index, so pages stay stable while assets are added.
+func (s *Store) List(q Query) (*Page, error) {
+	if q.Sort == "" {
+		q.Sort = SortUploaded
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultLimit
+	}
+
+	prefix := ownerPrefix(q.Owner)
+	page := &Page{Assets: []*Asset{}}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(indexBucket(q.Sort))
+		if bucket == nil {
+			return errors.New("assets: unknown sort " + string(q.Sort))
+		}
+		cursor := bucket.Cursor()
+
+		k, err := q.seek(cursor, prefix)
+		if err != nil {
+			return err
+		}
+
+		for ; k != nil && bytes.HasPrefix(k, prefix); k = q.next(cursor) {
+			position := k[len(prefix):]
+			if len(position) != indexKeySuffix {
+				continue
+			}
+			if !q.withinRange(position) {
+				break
+			}
+
+			id, _ := uuid.FromBytes(position[8:])
+			asset, err := get(tx, id)
+			if err != nil {
+				return err
+			}
+			if !q.matches(asset) {
+				continue
+			}
+
+			if len(page.Assets) == q.Limit {
+				// There is at least one more match; the page ends at the previous one.
+				last := page.Assets[len(page.Assets)-1]
+				page.NextCursor = encodeCursor(indexPosition(sortTime(last, q.Sort), last.ID))
+				break
+			}
+			page.Assets = append(page.Assets, asset)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// seek positions the cursor on the first key after q.Cursor, or at the start
+// of the requested range.
+func (q Query) seek(cursor *bolt.Cursor, prefix []byte) ([]byte, error) {
+	if q.Cursor != "" {
+		position, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after := append(bytes.Clone(prefix), position...)
+		k, _ := cursor.Seek(after)
+		switch {
+		case k == nil && !q.Ascending:
+			k, _ = cursor.Last()
+			return k, nil
+		case bytes.Equal(k, after) || !q.Ascending:
+			return q.next(cursor), nil
+		}
+		return k, nil
+	}
+
+	if q.Ascending {
+		start := prefix
+		if !q.From.IsZero() {
+			start = append(bytes.Clone(prefix), timeBytes(q.From)...)
+		}
+		k, _ := cursor.Seek(start)
+		return k, nil
+	}
+
+	// Descending: start at the last key at or before To.
+	end := append(bytes.Clone(prefix), 0xff)
+	if !q.To.IsZero() {
+		end = append(bytes.Clone(prefix), timeBytes(q.To.Add(time.Nanosecond))...)
+	}
+	if k, _ := cursor.Seek(end); k == nil {
+		k, _ = cursor.Last()
+		return k, nil
+	}
+	k, _ := cursor.Prev()
+	return k, nil
+}
+
+func (q Query) next(cursor *bolt.Cursor) []byte {
+	var k []byte
+	if q.Ascending {
+		k, _ = cursor.Next()
+	} else {
+		k, _ = cursor.Prev()
+	}
+	return k
+}
+
+// withinRange reports whether an index position is inside From..To in the
+// direction of travel; once it isn't, no later key can be.
+func (q Query) withinRange(position []byte) bool {
+	t := position[:8]
+	if q.Ascending {
+		return q.To.IsZero() || bytes.Compare(t, timeBytes(q.To)) <= 0
+	}
+	return q.From.IsZero() || bytes.Compare(t, timeBytes(q.From)) >= 0
+}
+
+func (q Query) matches(a *Asset) bool {
+	switch {
+	case q.Namespace != "" && a.Namespace != q.Namespace,
+		q.Directory != uuid.Nil && a.Directory != q.Directory,
+		q.MediaType != "" && a.MediaType != q.MediaType,
+		q.Make != "" && !strings.EqualFold(a.Camera.Make, q.Make),
+		q.Model != "" && !strings.EqualFold(a.Camera.Model, q.Model),
+		a.Width < q.MinWidth,
+		a.Height < q.MinHeight:
+		return false
+	}
+
+	if len(q.Statuses) > 0 && !containsStatus(q.Statuses, a.Status) {
+		return false
+	}
+
+	if q.Box != nil || q.Near != nil {
+		if a.Location == nil {
+			return false
+		}
+		if q.Box != nil && !q.Box.Contains(a.Location.Latitude, a.Location.Longitude) {
+			return false
+		}
+		if q.Near != nil && distance(q.Near.Lat, q.Near.Lon, a.Location.Latitude, a.Location.Longitude) > q.Near.Radius {
+			return false
+		}
+	}
+	return true
+}
+
+func containsStatus(statuses []Status, status Status) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains handles boxes crossing the antimeridian, where MinLon > MaxLon.
+func (b *BoundingBox) Contains(lat, lon float64) bool {
+	if lat < b.MinLat || lat > b.MaxLat {
+		return false
+	}
+	if b.MinLon <= b.MaxLon {
+		return lon >= b.MinLon && lon <= b.MaxLon
+	}
+	return lon >= b.MinLon || lon <= b.MaxLon
+}
+
+const earthRadius = 6371000 // metres
+
+// distance is the haversine great-circle distance in metres.
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
+
+func encodeCursor(position []byte) string {
+	return base64.RawURLEncoding.EncodeToString(position)
+}
+
+func decodeCursor(cursor string) ([]byte, error) {
+	position, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(position) != indexKeySuffix {
+		return nil, ErrInvalidCursor
+	}
+	return position, nil
+}
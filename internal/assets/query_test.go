@@ -0,0 +1,143 @@
+package assets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func seedAssets(t *testing.T, store *Store, base time.Time) []*Asset {
+	var seeded []*Asset
+	for i := range 5 {
+		asset := &Asset{
+			ID:         uuid.New(),
+			Owner:      "u1",
+			Namespace:  "com.iris.photos",
+			MediaType:  MediaImage,
+			Status:     StatusReady,
+			Width:      1000 * (i + 1),
+			Height:     800,
+			UploadedAt: base.Add(time.Duration(i) * time.Hour),
+		}
+		if i == 2 {
+			asset.Owner = "u2"
+			asset.MediaType = MediaVideo
+			asset.Camera.Make = "Apple"
+			asset.Location = &Location{Latitude: 35.70, Longitude: 51.40}
+		}
+		if err := store.Put(asset); err != nil {
+			t.Fatal(err)
+		}
+		seeded = append(seeded, asset)
+	}
+	return seeded
+}
+
+func ids(page *Page) []uuid.UUID {
+	var out []uuid.UUID
+	for _, a := range page.Assets {
+		out = append(out, a.ID)
+	}
+	return out
+}
+
+func TestListPaginatesNewestFirst(t *testing.T) {
+
+	store := openTestStore(t)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	seeded := seedAssets(t, store, base)
+
+	var got []uuid.UUID
+	q := Query{Owner: "u1", Limit: 2}
+	for {
+		page, err := store.List(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, ids(page)...)
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	want := []uuid.UUID{seeded[4].ID, seeded[3].ID, seeded[1].ID, seeded[0].ID}
+	if len(got) != len(want) {
+		t.Fatalf("got %d assets, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("position %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestListAscendingWithRange(t *testing.T) {
+
+	store := openTestStore(t)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	seeded := seedAssets(t, store, base)
+
+	page, err := store.List(Query{
+		Ascending: true,
+		From:      base.Add(time.Hour),
+		To:        base.Add(3 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ids(page)
+	if len(got) != 3 || got[0] != seeded[1].ID || got[2] != seeded[3].ID {
+		t.Errorf("unexpected range result %v", got)
+	}
+}
+
+func TestListFilters(t *testing.T) {
+
+	store := openTestStore(t)
+	seeded := seedAssets(t, store, time.Now())
+
+	tests := []struct {
+		name string
+		q    Query
+		want int
+	}{
+		{"media type", Query{MediaType: MediaVideo}, 1},
+		{"camera", Query{Make: "apple"}, 1},
+		{"min width", Query{MinWidth: 4000}, 2},
+		{"box", Query{Box: &BoundingBox{MinLat: 35, MinLon: 51, MaxLat: 36, MaxLon: 52}}, 1},
+		{"near", Query{Near: &Circle{Lat: 35.71, Lon: 51.41, Radius: 2000}}, 1},
+		{"too far", Query{Near: &Circle{Lat: 35.71, Lon: 51.41, Radius: 100}}, 0},
+		{"namespace", Query{Namespace: "com.iris.messages"}, 0},
+	}
+	for _, tt := range tests {
+		page, err := store.List(tt.q)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(page.Assets) != tt.want {
+			t.Errorf("%s: got %d assets, want %d", tt.name, len(page.Assets), tt.want)
+		}
+	}
+
+	// Updating the sort time moves the asset in the index.
+	if _, err := store.Update(seeded[0].ID, func(a *Asset) error {
+		a.CapturedAt = time.Now().Add(24 * time.Hour)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	page, _ := store.List(Query{Sort: SortCaptured, Limit: 1})
+	if len(page.Assets) != 1 || page.Assets[0].ID != seeded[0].ID {
+		t.Errorf("expected the recaptured asset first")
+	}
+}
+
+func TestListRejectsBadCursor(t *testing.T) {
+
+	store := openTestStore(t)
+	if _, err := store.List(Query{Cursor: "not-a-cursor"}); err != ErrInvalidCursor {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+}
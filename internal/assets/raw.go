@@ -0,0 +1,63 @@
+package assets
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// JPEGRendition names, in Asset.Renditions, the JPEG a camera wrote
+// alongside a RAW.
+const JPEGRendition = "jpeg"
+
+// Raw describes a camera RAW original.
+type Raw struct {
+	Format string `json:"format"` // media format, e.g. "cr3"
+
+	// JPEGID is the ID the camera's JPEG was uploaded under before it was
+	// merged into the RAW as its JPEGRendition.
+	JPEGID *uuid.UUID `json:"jpegId,omitempty"`
+}
+
+// RawPartner finds the other half of a RAW+JPEG shot: a ready image in the
+// same upload directory with the same file name apart from the extension
+// and, when both are known, the same capture time. It returns ErrNotFound
+// when it hasn't been uploaded yet.
+func (s *Store) RawPartner(asset *Asset) (*Asset, error) {
+	if asset.MediaType != MediaImage || asset.Filename == "" {
+		return nil, ErrNotFound
+	}
+	return s.findPartner(asset, MediaImage, func(candidate *Asset) bool {
+		if asset.Raw != nil {
+			return candidate.Raw == nil && sameShot(asset, candidate)
+		}
+		return candidate.Raw != nil && candidate.Raw.JPEGID == nil && sameShot(candidate, asset)
+	})
+}
+
+// PairRaw merges a camera JPEG into the RAW shot with it. The RAW stays the
+// original, the JPEG becomes its JPEGRendition with its renditions under
+// "jpeg_<name>", and the JPEG's own record is removed.
+func (s *Store) PairRaw(rawID, jpegID uuid.UUID) (*Asset, error) {
+	return s.absorb(rawID, jpegID, JPEGRendition,
+		func(raw, jpeg *Asset) bool {
+			return raw.Raw != nil && jpeg.Raw == nil && jpeg.MediaType == MediaImage && sameShot(raw, jpeg)
+		},
+		func(raw, jpeg *Asset) {
+			raw.Raw.JPEGID = &jpeg.ID
+		})
+}
+
+// sameShot reports whether a RAW and a JPEG were written for the same
+// exposure, as cameras name them IMG_0001.CR3 and IMG_0001.JPG.
+func sameShot(raw, jpeg *Asset) bool {
+	if raw.Filename == "" || !strings.EqualFold(baseName(raw.Filename), baseName(jpeg.Filename)) {
+		return false
+	}
+	return raw.CapturedAt.IsZero() || jpeg.CapturedAt.IsZero() || raw.CapturedAt.Equal(jpeg.CapturedAt)
+}
+
+func baseName(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename))
+}
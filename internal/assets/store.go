@@ -0,0 +1,143 @@
+package assets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var ErrNotFound = errors.New("asset not found")
+
+var (
+	assetsBucket = []byte("assets")
+	metaBucket   = []byte("meta")
+	versionKey   = []byte("schemaVersion")
+)
+
+// Store keeps asset records in a bbolt file.
+type Store struct {
+	db  *bolt.DB
+	now func() time.Time
+}
+
+// Open opens or creates the database at path and applies pending migrations.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("assets: open %s: %w", path, err)
+	}
+
+	store := &Store{db: db, now: time.Now}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Get returns the asset with the given ID.
+func (s *Store) Get(id uuid.UUID) (*Asset, error) {
+	var asset *Asset
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		asset, err = get(tx, id)
+		return err
+	})
+	return asset, err
+}
+
+// Put creates or replaces an asset record.
+func (s *Store) Put(asset *Asset) error {
+	now := s.now().UTC()
+	if asset.UploadedAt.IsZero() {
+		asset.UploadedAt = now
+	}
+	asset.UpdatedAt = now
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return put(tx, asset)
+	})
+}
+
+// Update applies fn to the stored asset and saves the result atomically.
+// Returning an error from fn leaves the record unchanged.
+func (s *Store) Update(id uuid.UUID, fn func(*Asset) error) (*Asset, error) {
+	var asset *Asset
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if asset, err = get(tx, id); err != nil {
+			return err
+		}
+		if err := fn(asset); err != nil {
+			return err
+		}
+		asset.UpdatedAt = s.now().UTC()
+		return put(tx, asset)
+	})
+	return asset, err
+}
+
+// Delete removes the record for id.
+func (s *Store) Delete(id uuid.UUID) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if _, err := get(tx, id); err != nil {
+			return err
+		}
+		return del(tx, id)
+	})
+}
+
+func get(tx *bolt.Tx, id uuid.UUID) (*Asset, error) {
+	data := tx.Bucket(assetsBucket).Get(id[:])
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	var asset Asset
+	if err := json.Unmarshal(data, &asset); err != nil {
+		return nil, fmt.Errorf("assets: decode %s: %w", id, err)
+	}
+	return &asset, nil
+}
+
+func put(tx *bolt.Tx, asset *Asset) error {
+	if old, err := get(tx, asset.ID); err == nil {
+		if err := unindex(tx, old); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	data, err := json.Marshal(asset)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(assetsBucket).Put(asset.ID[:], data); err != nil {
+		return err
+	}
+	return index(tx, asset)
+}
+
+func del(tx *bolt.Tx, id uuid.UUID) error {
+	old, err := get(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := unindex(tx, old); err != nil {
+		return err
+	}
+	return tx.Bucket(assetsBucket).Delete(id[:])
+}
@@ -0,0 +1,235 @@
+package assets
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/exiftool"
+)
+
+func openTestStore(t *testing.T) *Store {
+	store, err := Open(filepath.Join(t.TempDir(), "assets.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestPutGetUpdateDelete(t *testing.T) {
+
+	store := openTestStore(t)
+	id := uuid.New()
+
+	if err := store.Put(&Asset{ID: id, Owner: "u1", Status: StatusProcessing}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	updated, err := store.Update(id, func(a *Asset) error {
+		a.Status = StatusReady
+		return nil
+	})
+	if err != nil || updated.Status != StatusReady {
+		t.Fatalf("Update = %+v, %v", updated, err)
+	}
+
+	errAbort := errors.New("abort")
+	if _, err := store.Update(id, func(a *Asset) error {
+		a.Status = StatusFailed
+		return errAbort
+	}); !errors.Is(err, errAbort) {
+		t.Fatalf("expected the update error, got %v", err)
+	}
+
+	got, err := store.Get(id)
+	if err != nil || got.Status != StatusReady || got.Owner != "u1" || got.UploadedAt.IsZero() {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+
+	if err := store.Delete(id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestMigrationsAreRecorded(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "assets.db")
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// Reopening must not re-run or fail on applied migrations.
+	store, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer store.Close()
+}
+
+func TestApplyMetadata(t *testing.T) {
+
+	asset := &Asset{MediaType: MediaVideo}
+	md := &exiftool.Metadata{}
+	md.Video.Width, md.Video.Height = 1920, 1080
+	md.Video.Duration = 83
+	md.Camera.Make = "Apple"
+	md.Location.Latitude = 35.7
+
+	asset.ApplyMetadata(md)
+
+	if asset.Width != 1920 || asset.Duration != 83 || asset.Camera.Make != "Apple" || asset.Location == nil {
+		t.Errorf("unexpected asset %+v", asset)
+	}
+}
+
+func TestPairLive(t *testing.T) {
+
+	store := openTestStore(t)
+	dir := uuid.New()
+	live := func(mediaType MediaType, contentID string, size int64) *Asset {
+		asset := &Asset{
+			ID: uuid.New(), Owner: "u1", Namespace: "com.iris.photos", Directory: dir,
+			MediaType: mediaType, Status: StatusReady, Size: size,
+			Original:   "/up/" + string(mediaType),
+			Renditions: map[string]string{"270": "/up/" + string(mediaType) + "_270.jpg"},
+			Motion:     &Motion{Kind: MotionLive, ContentID: contentID},
+		}
+		if err := store.Put(asset); err != nil {
+			t.Fatal(err)
+		}
+		return asset
+	}
+
+	still := live(MediaImage, "A1", 100)
+	if _, err := store.LivePartner(still); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected no partner before the video, got %v", err)
+	}
+	live(MediaVideo, "B2", 10) // another Live Photo in the same directory
+	video := live(MediaVideo, "A1", 50)
+
+	partner, err := store.LivePartner(still)
+	if err != nil || partner.ID != video.ID {
+		t.Fatalf("LivePartner = %+v, %v", partner, err)
+	}
+
+	paired, err := store.PairLive(still.ID, video.ID)
+	if err != nil {
+		t.Fatalf("PairLive: %v", err)
+	}
+	if !paired.Paired() || paired.Renditions[MotionRendition] != "/up/video" ||
+		paired.Renditions["motion_270"] != "/up/video_270.jpg" || paired.Size != 150 ||
+		paired.Motion.VideoID == nil || *paired.Motion.VideoID != video.ID {
+		t.Fatalf("unexpected paired still %+v", paired)
+	}
+	if _, err := store.Get(video.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("video record should be merged away, got %v", err)
+	}
+
+	if _, err := store.PairLive(still.ID, live(MediaVideo, "A1", 5).ID); !errors.Is(err, ErrNotPairable) {
+		t.Fatalf("expected ErrNotPairable for an already paired still, got %v", err)
+	}
+}
+
+func TestApplyMetadataContentIdentifier(t *testing.T) {
+
+	asset := &Asset{MediaType: MediaImage}
+	md := &exiftool.Metadata{}
+	md.Motion.ContentIdentifier = "A1"
+
+	asset.ApplyMetadata(md)
+
+	if asset.Motion == nil || asset.Motion.Kind != MotionLive || asset.Motion.ContentID != "A1" {
+		t.Errorf("unexpected motion %+v", asset.Motion)
+	}
+}
+
+func TestPairRaw(t *testing.T) {
+
+	store := openTestStore(t)
+	dir := uuid.New()
+	shot := func(filename string, raw *Raw) *Asset {
+		asset := &Asset{
+			ID: uuid.New(), Owner: "u1", Directory: dir, MediaType: MediaImage, Status: StatusReady,
+			Original: "/up/" + filename, Filename: filename, Size: 10, Raw: raw,
+		}
+		if err := store.Put(asset); err != nil {
+			t.Fatal(err)
+		}
+		return asset
+	}
+
+	jpeg := shot("IMG_0001.JPG", nil)
+	shot("IMG_0002.JPG", nil)
+	raw := shot("IMG_0001.CR3", &Raw{Format: "cr3"})
+
+	for _, asset := range []*Asset{raw, jpeg} {
+		partner, err := store.RawPartner(asset)
+		if err != nil || (partner.ID != raw.ID && partner.ID != jpeg.ID) || partner.ID == asset.ID {
+			t.Fatalf("RawPartner(%s) = %+v, %v", asset.Filename, partner, err)
+		}
+	}
+
+	if _, err := store.PairRaw(jpeg.ID, raw.ID); !errors.Is(err, ErrNotPairable) {
+		t.Fatalf("the JPEG can't keep the RAW, got %v", err)
+	}
+	paired, err := store.PairRaw(raw.ID, jpeg.ID)
+	if err != nil {
+		t.Fatalf("PairRaw: %v", err)
+	}
+	if paired.Original != "/up/IMG_0001.CR3" || paired.Renditions[JPEGRendition] != "/up/IMG_0001.JPG" ||
+		paired.Size != 20 || paired.Raw.JPEGID == nil || *paired.Raw.JPEGID != jpeg.ID {
+		t.Fatalf("unexpected paired RAW %+v", paired)
+	}
+	if _, err := store.RawPartner(paired); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("IMG_0002 is not IMG_0001's JPEG, got %v", err)
+	}
+}
+
+func TestApplyMetadataAnimation(t *testing.T) {
+
+	asset := &Asset{MediaType: MediaImage}
+	md := &exiftool.Metadata{}
+	md.Image.FrameCount = 24
+	md.Image.AnimationDuration = 2.4
+
+	asset.ApplyMetadata(md)
+
+	if asset.Frames != 24 || asset.Duration != 2.4 {
+		t.Errorf("unexpected animation fields: frames %d, duration %v", asset.Frames, asset.Duration)
+	}
+}
+
+func TestApplyMetadataDocument(t *testing.T) {
+
+	asset := &Asset{MediaType: MediaDocument}
+	md := &exiftool.Metadata{}
+	md.FileInfo.MimeType = "application/pdf"
+	md.Document = exiftool.DocumentInfo{PageCount: 12, Title: "Invoice", Author: "Iris"}
+
+	asset.ApplyMetadata(md)
+
+	if asset.Pages != 12 || asset.Document == nil || asset.Document.Title != "Invoice" || asset.Document.Author != "Iris" {
+		t.Errorf("unexpected document fields: pages %d, %+v", asset.Pages, asset.Document)
+	}
+}
+
+func TestApplyMetadataAudio(t *testing.T) {
+
+	asset := &Asset{MediaType: MediaAudio}
+	md := &exiftool.Metadata{}
+	md.FileInfo.MimeType = "audio/mpeg"
+	md.Audio = exiftool.AudioInfo{Duration: 205, Codec: "MP3", Bitrate: 320000, SampleRate: 44100, Channels: 2, Artist: "Iris", Peaks: []int{0, 50, 100}}
+
+	asset.ApplyMetadata(md)
+
+	if asset.Duration != 205 || asset.Audio == nil || asset.Audio.Bitrate != 320000 || asset.Audio.Artist != "Iris" || len(asset.Audio.Peaks) != 3 {
+		t.Errorf("unexpected audio fields: duration %v, %+v", asset.Duration, asset.Audio)
+	}
+}
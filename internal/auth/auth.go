@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// APIKeyHeader carries a static key for service-to-service calls.
+const APIKeyHeader = "X-API-Key"
+
+// OnBehalfOfHeader lets an authenticated service act for one of its users.
+// It is ignored for end-user tokens.
+const OnBehalfOfHeader = "X-User-ID"
+
+var (
+	ErrNoCredentials  = errors.New("authentication required")
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrNoSubject      = errors.New("token has no subject")
+	ErrNotConfigured  = errors.New("authentication is not configured")
+	ErrUnknownKeyID   = errors.New("unknown token key id")
+	ErrUnsupportedKey = errors.New("unsupported JWKS key")
+	ErrInvalidUserID  = errors.New("user ID must be a UUID")
+)
+
+// Identity is the verified caller of a request.
+type Identity struct {
+	UserID  string // user the request acts for; empty for a service acting for itself
+	Service string // service name when authenticated with an API key
+}
+
+// IsService reports whether the caller authenticated with an API key.
+func (i *Identity) IsService() bool {
+	return i.Service != ""
+}
+
+type Config struct {
+	HMACSecret []byte            // HS256/384/512 secret, optional
+	JWKSFile   string            // local JWKS file with RSA public keys, optional
+	APIKeys    map[string]string // API key -> service name
+	Issuer     string            // required "iss" when set
+	Audience   string            // required "aud" when set
+}
+
+// Authenticator verifies JWTs and API keys.
+type Authenticator struct {
+	hmacSecret []byte
+	rsaKeys    map[string]*rsa.PublicKey
+	apiKeys    map[string]string
+	parser     *jwt.Parser
+}
+
+func NewAuthenticator(cfg Config) (*Authenticator, error) {
+
+	a := &Authenticator{
+		hmacSecret: cfg.HMACSecret,
+		rsaKeys:    make(map[string]*rsa.PublicKey),
+		apiKeys:    cfg.APIKeys,
+	}
+
+	if cfg.JWKSFile != "" {
+		keys, err := LoadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.rsaKeys = keys
+	}
+
+	if len(a.hmacSecret) == 0 && len(a.rsaKeys) == 0 && len(a.apiKeys) == 0 {
+		return nil, ErrNotConfigured
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512"}),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+	a.parser = jwt.NewParser(options...)
+
+	return a, nil
+}
+
+// Authenticate checks the Authorization bearer token or the API key of r.
+func (a *Authenticator) Authenticate(r *http.Request) (*Identity, error) {
+
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		service, ok := a.lookupAPIKey(key)
+		if !ok {
+			return nil, ErrInvalidAPIKey
+		}
+		userID := r.Header.Get(OnBehalfOfHeader)
+		if userID != "" && !validUserID(userID) {
+			return nil, ErrInvalidUserID
+		}
+		return &Identity{Service: service, UserID: userID}, nil
+	}
+
+	header := r.Header.Get("Authorization")
+	raw, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || raw == "" {
+		return nil, ErrNoCredentials
+	}
+
+	return a.VerifyToken(strings.TrimSpace(raw))
+}
+
+// VerifyToken validates a JWT and returns the identity of its subject.
+func (a *Authenticator) VerifyToken(raw string) (*Identity, error) {
+
+	claims := &jwt.RegisteredClaims{}
+	if _, err := a.parser.ParseWithClaims(raw, claims, a.keyFunc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, ErrNoSubject
+	}
+	if !validUserID(claims.Subject) {
+		return nil, ErrInvalidUserID
+	}
+
+	return &Identity{UserID: claims.Subject}, nil
+}
+
+func (a *Authenticator) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(a.hmacSecret) == 0 {
+			return nil, ErrNotConfigured
+		}
+		return a.hmacSecret, nil
+	case *jwt.SigningMethodRSA:
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" && len(a.rsaKeys) == 1 {
+			for _, key := range a.rsaKeys {
+				return key, nil
+			}
+		}
+		key, ok := a.rsaKeys[kid]
+		if !ok {
+			return nil, ErrUnknownKeyID
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+}
+
+// validUserID keeps user IDs safe to use as a directory name.
+func validUserID(userID string) bool {
+	_, err := uuid.Parse(userID)
+	return err == nil
+}
+
+func (a *Authenticator) lookupAPIKey(key string) (string, bool) {
+	for candidate, service := range a.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+			return service, true
+		}
+	}
+	return "", false
+}
+
+// ParseAPIKeys reads API keys from "key:service,key:service".
+func ParseAPIKeys(value string) (map[string]string, error) {
+	keys := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, service, ok := strings.Cut(pair, ":")
+		if !ok || key == "" || service == "" {
+			return nil, errors.New("auth: API keys must be in key:service form")
+		}
+		keys[strings.TrimSpace(key)] = strings.TrimSpace(service)
+	}
+	return keys, nil
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// LoadJWKS reads RSA public keys from a JWKS file, keyed by kid.
+func LoadJWKS(path string) (map[string]*rsa.PublicKey, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read JWKS: %w", err)
+	}
+
+	var set jwks
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("%w: kid %q modulus: %v", ErrUnsupportedKey, key.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("%w: kid %q exponent", ErrUnsupportedKey, key.Kid)
+		}
+		keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS %s has no RSA signing keys", path)
+	}
+	return keys, nil
+}
+
+// OwnsPath reports whether path lies inside a directory scoped to userID,
+// i.e. its first users or uploads segment is followed by <id>. Later pairs
+// don't count, so another user's tree can't be claimed by nesting.
+func OwnsPath(userID, path string) bool {
+	if userID == "" {
+		return false
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if slices.Contains(segments, "..") {
+		return false
+	}
+	for i := 0; i+1 < len(segments); i++ {
+		if segments[i] == "users" || segments[i] == "uploads" {
+			return segments[i+1] == userID
+		}
+	}
+	return false
+}
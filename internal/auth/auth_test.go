@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testUserID = "018f3a8b-1b32-729a-f7e5-5467c1b2d3e4"
+
+func signHS256(t *testing.T, secret []byte, claims jwt.RegisteredClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
+
+func bearerRequest(token string) *http.Request {
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func TestAuthenticateHS256(t *testing.T) {
+
+	secret := []byte("test-secret")
+	a, err := NewAuthenticator(Config{HMACSecret: secret, Issuer: "iris"})
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+
+	valid := signHS256(t, secret, jwt.RegisteredClaims{
+		Subject:   testUserID,
+		Issuer:    "iris",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	identity, err := a.Authenticate(bearerRequest(valid))
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if identity.UserID != testUserID || identity.IsService() {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+
+	expired := signHS256(t, secret, jwt.RegisteredClaims{
+		Subject:   testUserID,
+		Issuer:    "iris",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+	})
+	if _, err := a.Authenticate(bearerRequest(expired)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expired token: got %v, want ErrInvalidToken", err)
+	}
+
+	wrongSecret := signHS256(t, []byte("other"), jwt.RegisteredClaims{
+		Subject:   testUserID,
+		Issuer:    "iris",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	if _, err := a.Authenticate(bearerRequest(wrongSecret)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("wrong secret: got %v, want ErrInvalidToken", err)
+	}
+
+	badSubject := signHS256(t, secret, jwt.RegisteredClaims{
+		Subject:   "../../etc",
+		Issuer:    "iris",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	if _, err := a.Authenticate(bearerRequest(badSubject)); !errors.Is(err, ErrInvalidUserID) {
+		t.Errorf("path-like subject: got %v, want ErrInvalidUserID", err)
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("anonymous: got %v, want ErrNoCredentials", err)
+	}
+}
+
+func TestAuthenticateRS256WithJWKS(t *testing.T) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	set := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "main",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	data, _ := json.Marshal(set)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewAuthenticator(Config{JWKSFile: path})
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+		Subject:   testUserID,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	token.Header["kid"] = "main"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+
+	identity, err := a.Authenticate(bearerRequest(signed))
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if identity.UserID != testUserID {
+		t.Errorf("user id = %q", identity.UserID)
+	}
+
+	// An HS256 token must not be accepted when no secret is configured.
+	hs := signHS256(t, []byte("anything"), jwt.RegisteredClaims{
+		Subject:   testUserID,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	if _, err := a.Authenticate(bearerRequest(hs)); err == nil {
+		t.Errorf("HS256 token accepted without a configured secret")
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+
+	a, err := NewAuthenticator(Config{APIKeys: map[string]string{"k-123": "com.iris.messages"}})
+	if err != nil {
+		t.Fatalf("NewAuthenticator: %v", err)
+	}
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(APIKeyHeader, "k-123")
+	r.Header.Set(OnBehalfOfHeader, testUserID)
+
+	identity, err := a.Authenticate(r)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if identity.Service != "com.iris.messages" || identity.UserID != testUserID {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+
+	r.Header.Set(APIKeyHeader, "wrong")
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("wrong key: got %v, want ErrInvalidAPIKey", err)
+	}
+}
+
+func TestOwnsPath(t *testing.T) {
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/com.iris.photos/users/" + testUserID + "/assets/a.jpg", true},
+		{"/app/iris/services/uploads/" + testUserID + "/dir/a.jpg", true},
+		{"/com.iris.photos/users/018f3a8b-0000-0000-0000-000000000000/assets/a.jpg", false},
+		{"/com.iris.messages/chats/018f3a8b-1b32-7295-a2c7-87654b4d4567/assets/a.jpg", false},
+		{"/com.iris.photos/users/" + testUserID + "/../other/a.jpg", false},
+		{"/com.iris.photos/users/018f3a8b-0000-0000-0000-000000000000/assets/users/" + testUserID + "/a.jpg", false},
+		{"/com.iris.photos/users/018f3a8b-0000-0000-0000-000000000000/uploads/" + testUserID + "/a.jpg", false},
+	}
+
+	for _, tt := range tests {
+		if got := OwnsPath(testUserID, tt.path); got != tt.want {
+			t.Errorf("OwnsPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/helpers"
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+)
+
+// IdentityKey is the gin context key holding the verified *Identity.
+const IdentityKey = "identity"
+
+// Middleware rejects requests that are not authenticated and stores the
+// verified user ID under helpers.UserIDKey.
+func Middleware(a *Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, err := a.Authenticate(c.Request)
+		if err != nil {
+			helpers.AbortWithError(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		setIdentity(c, identity)
+		c.Next()
+	}
+}
+
+// Optional authenticates the request when credentials are present but lets
+// anonymous requests through, so later middleware can accept signed URLs.
+func Optional(a *Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, err := a.Authenticate(c.Request)
+		if err == nil {
+			setIdentity(c, identity)
+		} else if !errors.Is(err, ErrNoCredentials) {
+			helpers.AbortWithError(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		c.Next()
+	}
+}
+
+func setIdentity(c *gin.Context, identity *Identity) {
+	c.Set(IdentityKey, identity)
+	if identity.UserID != "" {
+		c.Set(helpers.UserIDKey, identity.UserID)
+		logging.AddAttrs(c, "user_id", identity.UserID)
+	}
+}
+
+// GetIdentity returns the identity stored by Middleware or Optional.
+func GetIdentity(c *gin.Context) (*Identity, bool) {
+	value, exists := c.Get(IdentityKey)
+	if !exists {
+		return nil, false
+	}
+	identity, ok := value.(*Identity)
+	return identity, ok
+}
+
+// RequireService only lets callers authenticated with an API key through.
+// It must run after Middleware.
+func RequireService() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, ok := GetIdentity(c)
+		if !ok || !identity.IsService() {
+			helpers.AbortWithError(c, http.StatusForbidden, "this endpoint is restricted to services")
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,5 +1,192 @@
 package config
 
+import (
+	"image/color"
+	"runtime"
+	"time"
+)
+
 const (
 	UploadDir = "/app/iris/services/uploads"
+
+	// ServiceName identifies this service in traces.
+	ServiceName = "upload-service"
+
+	// TraceExporterEnv selects where spans go: "otlp", "stdout" or "none" (default).
+	TraceExporterEnv = "UPLOAD_TRACE_EXPORTER"
+
+	// Log output is configured from these environment variables:
+	// format "text" (default) or "json", level "debug", "info", "warn" or "error".
+	LogFormatEnv = "UPLOAD_LOG_FORMAT"
+	LogLevelEnv  = "UPLOAD_LOG_LEVEL"
+
+	// In-memory image caches evict the least recently used image once either
+	// limit is reached.
+	IconCacheEntries      = 5000
+	IconCacheBytes        = 64 << 20 // 64 MB
+	OriginalCacheEntries  = 100
+	OriginalCacheBytes    = 512 << 20 // 512 MB
+	ThumbnailCacheEntries = 5000
+	ThumbnailCacheBytes   = 256 << 20 // 256 MB
+
+	// CacheWarmupEnv names the environment variable holding how many recently
+	// uploaded assets have their thumbnails loaded at startup. Unset or 0 skips it.
+	CacheWarmupEnv = "UPLOAD_CACHE_WARMUP"
+
+	// HEIF originals (HEIC, AVIF) get a full-resolution "display" copy in
+	// DisplayFormat, "jpeg" or "avif", for clients that can't decode them.
+	DisplayFormat  = "jpeg"
+	DisplayQuality = 90
+
+	// DerivativeColor is what thumbnails and renders do with wide-gamut
+	// originals such as Display P3 photos: "srgb" converts them using the
+	// embedded ICC profile, "preserve" keeps the profile and the pixels.
+	// Renders can ask for either with the color parameter.
+	DerivativeColor = "srgb"
+
+	// Documents (PDF) get a cover JPEG of their first page this wide, from
+	// which the thumbnails are made.
+	DocumentCoverWidth = 1280
+
+	// Audio uploads are kept as they are and, when AudioTranscodeCodec is
+	// "opus" or "aac", also re-encoded at AudioTranscodeBitrate bits per
+	// second for clients that can't play the original. Empty turns it off.
+	AudioTranscodeCodec   = ""
+	AudioTranscodeBitrate = 64000
+
+	// Waveforms are drawn from audio decoded at WaveformSampleRate, keeping
+	// the loudest sample of every WaveformBlock, then reduced to
+	// WaveformPeaks values and a WaveformWidth x WaveformHeight PNG.
+	WaveformSampleRate = 8000
+	WaveformBlock      = 80 // 10 ms
+	WaveformPeaks      = 100
+	WaveformWidth      = 600
+	WaveformHeight     = 120
+
+	// Videos get a storyboard for scrub previews: a frame every
+	// StoryboardInterval, or fewer so there are at most StoryboardMaxTiles,
+	// StoryboardTileWidth pixels wide, tiled StoryboardColumns x StoryboardRows
+	// to a JPEG sprite sheet and listed in a WebVTT thumbnails track.
+	StoryboardInterval  = 2 * time.Second
+	StoryboardMaxTiles  = 600
+	StoryboardTileWidth = 160
+	StoryboardColumns   = 10
+	StoryboardRows      = 10
+
+	// Videos get a muted PreviewClipLength loop for gallery tiles, starting
+	// at the biggest scene change, <id>_preview.mp4, and with PreviewClipWebP
+	// an animated WebP too. A clip over PreviewClipMaxBytes is dropped.
+	PreviewClipLength   = 3 * time.Second
+	PreviewClipWidth    = 320
+	PreviewClipFPS      = 15
+	PreviewClipMaxBytes = 512 << 10 // 512 KB
+	PreviewClipWebP     = false
+
+	// Animated GIF, WebP and AVIF uploads also get animated WebP thumbnails,
+	// cut after AnimatedMaxFrames frames or AnimatedMaxDuration, and a
+	// "poster" JPEG of their first frame.
+	AnimatedMaxFrames   = 150
+	AnimatedMaxDuration = 10 * time.Second
+	AnimatedQuality     = 75
+
+	// RenderCacheDir holds variants produced by the download render endpoint.
+	RenderCacheDir = "/app/iris/services/cache/render"
+
+	// RenderDefaultQuality is used when a render request has no q parameter.
+	RenderDefaultQuality = 80
+
+	// SigningKeysEnv names the environment variable holding signed URL keys
+	// as "kid:secret,kid:secret". The first key signs new URLs.
+	SigningKeysEnv = "UPLOAD_SIGNING_KEYS"
+
+	SignedURLDefaultTTL = time.Hour
+	SignedURLMaxTTL     = 7 * 24 * time.Hour
+
+	// Authentication settings are read from these environment variables.
+	AuthJWTSecretEnv = "UPLOAD_JWT_SECRET" // HS256 secret
+	AuthJWKSFileEnv  = "UPLOAD_JWKS_FILE"  // path to a local JWKS with RSA keys
+	AuthAPIKeysEnv   = "UPLOAD_API_KEYS"   // "key:service,key:service"
+	AuthIssuerEnv    = "UPLOAD_JWT_ISSUER"
+	AuthAudienceEnv  = "UPLOAD_JWT_AUDIENCE"
+
+	// DefaultNamespace is used for uploads that don't name an app namespace.
+	DefaultNamespace = "com.iris.settings"
+
+	// AssetDBFile is the embedded database indexing every uploaded asset.
+	AssetDBFile = "/app/iris/services/assets.db"
+
+	// Asset events are recorded in EventOutboxFile, then relayed to in-process
+	// subscribers and, when EventRedisAddrEnv is set, to Redis.
+	EventRedisAddrEnv = "UPLOAD_REDIS_ADDR"
+	EventOutboxFile   = "/app/iris/services/events-outbox.db"
+	EventChannel      = "iris.upload.events" // pub/sub channel
+	EventStream       = "iris.upload.stream" // stream for consumers that replay
+	EventStreamMaxLen = 100000
+	EventRetryMin     = time.Second
+	EventRetryMax     = time.Minute
+
+	// Webhooks are signed with the secret in WebhookSecretEnv. Without one,
+	// callback URLs are refused and no webhooks are sent.
+	WebhookSecretEnv    = "UPLOAD_WEBHOOK_SECRET"
+	WebhookDBFile       = "/app/iris/services/webhooks.db"
+	WebhookTimeout      = 10 * time.Second
+	WebhookMaxAttempts  = 8 // about 21 minutes of retries with the backoff below
+	WebhookRetryMin     = 10 * time.Second
+	WebhookRetryMax     = 10 * time.Minute
+	WebhookPollInterval = 5 * time.Second
+	WebhookRetention    = 7 * 24 * time.Hour // delivered records; dead letters are kept
+	WebhookQueueSize    = 64                 // events buffered between the bus and the dispatcher
+
+	// TrashDir holds deleted assets until TrashRetention has passed.
+	TrashDir           = "/app/iris/services/trash"
+	TrashRetention     = 30 * 24 * time.Hour
+	TrashPurgeInterval = time.Hour
+
+	// UsageFile records how much each user stores.
+	UsageFile = "/app/iris/services/upload-usage.json"
+
+	MaxImageSize    = 100 << 20 // 100 MB
+	MaxVideoSize    = 4 << 30   // 4 GB
+	MaxDocumentSize = 50 << 20  // 50 MB
+	MaxAudioSize    = 500 << 20 // 500 MB
+	UserQuota       = 50 << 30  // 50 GB
+
+	// Token bucket limits per user, or per IP for anonymous requests.
+	UploadRatePerSecond   = 0.5 // 30 uploads a minute
+	UploadRateBurst       = 10
+	DownloadRatePerSecond = 50
+	DownloadRateBurst     = 200
+
+	// Heavy processing (ffmpeg, libvips, exiftool) shares one pool of slots.
+	JobQueueSize  = 32
+	JobQueueWait  = 30 * time.Second
+	JobRetryAfter = 10 * time.Second
+
+	// StorageDir is the root the download loaders serve from.
+	StorageDir = "/app/iris"
+
+	// Readiness fails when the upload or storage root has less free space than this.
+	MinFreeDiskBytes = 2 << 30 // 2 GB
+
+	HealthCheckTimeout  = 5 * time.Second
+	HealthCheckCacheTTL = 10 * time.Second
 )
+
+// RenderSizes are the only widths and heights the render endpoint will produce.
+// Keeping the set small stops clients from filling the cache with arbitrary sizes.
+var RenderSizes = []int{135, 270, 400, 540, 720, 1080, 1440, 2160}
+
+// NamespaceQuotas caps what a single user may store within an app namespace.
+var NamespaceQuotas = map[string]int64{
+	"com.iris.messages": 10 << 30,
+}
+
+// NamespaceWebhooks are callback URLs that receive processing events for every
+// upload into a namespace, unless the upload names its own callbackUrl.
+var NamespaceWebhooks = map[string]string{}
+
+// WaveformColor draws the bars of waveform PNGs on a transparent background.
+var WaveformColor = color.NRGBA{R: 0x34, G: 0x78, B: 0xF6, A: 0xFF}
+
+// MaxConcurrentJobs is how many heavy processing jobs may run at once.
+var MaxConcurrentJobs = runtime.NumCPU()
@@ -0,0 +1,109 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/assets"
+)
+
+// Type names what happened to an asset.
+type Type string
+
+const (
+	UploadReceived  Type = "upload.received"  // file accepted, processing started
+	UploadProcessed Type = "upload.processed" // renditions and metadata ready
+	UploadFailed    Type = "upload.failed"    // processing failed; see Event.Error
+	AssetCommitted  Type = "asset.committed"  // moved from the upload directory into storage
+	AssetPaired     Type = "asset.paired"     // a Live Photo's video or a camera JPEG merged into its still or RAW
+	AssetDeleted    Type = "asset.deleted"    // moved to the trash
+	AssetRestored   Type = "asset.restored"   // moved back out of the trash
+	AssetPurged     Type = "asset.purged"     // removed for good after the retention period
+)
+
+// Event is the JSON payload published for every asset change. Delivery is
+// at least once, so consumers should ignore IDs they have already seen.
+type Event struct {
+	ID        uuid.UUID `json:"id"`
+	Type      Type      `json:"type"`
+	Time      time.Time `json:"time"`
+	AssetID   uuid.UUID `json:"assetId"`
+	Owner     string    `json:"owner"`
+	Namespace string    `json:"namespace"`
+	Directory uuid.UUID `json:"directory"`
+	Error     string    `json:"error,omitempty"`
+	Asset     *Summary  `json:"asset,omitempty"`
+}
+
+// Summary is the part of an asset record consumers usually need, without
+// internal bookkeeping such as trash paths.
+type Summary struct {
+	MediaType  assets.MediaType  `json:"mediaType"`
+	Status     assets.Status     `json:"status"`
+	Original   string            `json:"original"`
+	Renditions map[string]string `json:"renditions,omitempty"`
+	SHA256     string            `json:"sha256,omitempty"`
+	Size       int64             `json:"size"`
+	MimeType   string            `json:"mimeType,omitempty"`
+	Width      int               `json:"width,omitempty"`
+	Height     int               `json:"height,omitempty"`
+	Duration   float64           `json:"duration,omitempty"`
+	CapturedAt *time.Time        `json:"capturedAt,omitempty"`
+	Camera     assets.Camera     `json:"camera"`
+	Location   *assets.Location  `json:"location,omitempty"`
+	Motion     *assets.Motion    `json:"motion,omitempty"`
+	Raw        *assets.Raw       `json:"raw,omitempty"`
+}
+
+// New builds an event of type t describing asset.
+func New(t Type, asset *assets.Asset) Event {
+	event := Event{
+		ID:        uuid.New(),
+		Type:      t,
+		Time:      time.Now().UTC(),
+		AssetID:   asset.ID,
+		Owner:     asset.Owner,
+		Namespace: asset.Namespace,
+		Directory: asset.Directory,
+		Error:     asset.Error,
+		Asset: &Summary{
+			MediaType:  asset.MediaType,
+			Status:     asset.Status,
+			Original:   asset.Original,
+			Renditions: asset.Renditions,
+			SHA256:     asset.SHA256,
+			Size:       asset.Size,
+			MimeType:   asset.MimeType,
+			Width:      asset.Width,
+			Height:     asset.Height,
+			Duration:   asset.Duration,
+			Camera:     asset.Camera,
+			Location:   asset.Location,
+			Motion:     asset.Motion,
+			Raw:        asset.Raw,
+		},
+	}
+	if !asset.CapturedAt.IsZero() {
+		capturedAt := asset.CapturedAt
+		event.Asset.CapturedAt = &capturedAt
+	}
+	return event
+}
+
+// Publisher delivers events to other services.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Multi publishes to every publisher in turn, stopping at the first error.
+type Multi []Publisher
+
+func (m Multi) Publish(ctx context.Context, event Event) error {
+	for _, publisher := range m {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/assets"
+	"github.com/redis/go-redis/v9"
+)
+
+func testAsset() *assets.Asset {
+	return &assets.Asset{
+		ID:         uuid.New(),
+		Owner:      "018f3a8b-1b32-729a-f7e5-5467c1b2d3e4",
+		Namespace:  "com.iris.photos",
+		Directory:  uuid.New(),
+		MediaType:  assets.MediaImage,
+		Status:     assets.StatusReady,
+		Original:   "/uploads/a.jpg",
+		Renditions: map[string]string{"270": "/uploads/a_270.jpg"},
+		Width:      4032,
+		Height:     3024,
+	}
+}
+
+// flaky fails until fail is cleared and records what it accepted.
+type flaky struct {
+	mu        sync.Mutex
+	fail      bool
+	delivered []Event
+}
+
+func (f *flaky) Publish(ctx context.Context, event Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return errors.New("target unavailable")
+	}
+	f.delivered = append(f.delivered, event)
+	return nil
+}
+
+func TestNewSummarisesAsset(t *testing.T) {
+
+	asset := testAsset()
+	event := New(UploadProcessed, asset)
+	if event.ID == uuid.Nil || event.AssetID != asset.ID || event.Asset.Width != 4032 || event.Asset.CapturedAt != nil {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
+
+func TestMemorySubscribe(t *testing.T) {
+
+	bus := NewMemory()
+	ch, cancel := bus.Subscribe(1)
+
+	event := New(UploadReceived, testAsset())
+	if err := bus.Publish(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-ch; got.ID != event.ID {
+		t.Errorf("received %v, want %v", got.ID, event.ID)
+	}
+
+	// A full subscriber that unsubscribes must not leave Publish blocked.
+	bus.Publish(context.Background(), event)
+	done := make(chan error)
+	go func() { done <- bus.Publish(context.Background(), event) }()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Publish stayed blocked after unsubscribe")
+	}
+}
+
+func TestOutboxKeepsEventsUntilDelivered(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "outbox.db")
+	target := &flaky{fail: true}
+	outbox, err := OpenOutbox(path, target, time.Millisecond, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	asset := testAsset()
+	for _, typ := range []Type{UploadReceived, UploadProcessed, AssetCommitted} {
+		if err := outbox.Publish(context.Background(), New(typ, asset)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if delivered, err := outbox.Flush(context.Background()); err == nil || delivered != 0 {
+		t.Fatalf("Flush against a failing target = %d, %v", delivered, err)
+	}
+
+	// Pending events survive a restart.
+	outbox.Close()
+	outbox, err = OpenOutbox(path, target, time.Millisecond, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outbox.Close()
+	if outbox.Pending() != 3 {
+		t.Fatalf("expected 3 pending events, got %d", outbox.Pending())
+	}
+
+	target.mu.Lock()
+	target.fail = false
+	target.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go outbox.Run(ctx)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for outbox.Pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	target.mu.Lock()
+	defer target.mu.Unlock()
+	if len(target.delivered) != 3 {
+		t.Fatalf("delivered %d events, want 3", len(target.delivered))
+	}
+	for i, typ := range []Type{UploadReceived, UploadProcessed, AssetCommitted} {
+		if target.delivered[i].Type != typ {
+			t.Errorf("event %d is %s, want %s", i, target.delivered[i].Type, typ)
+		}
+	}
+}
+
+func TestRedisPublishesToChannelAndStream(t *testing.T) {
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	sub := client.Subscribe(ctx, "events")
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	event := New(AssetCommitted, testAsset())
+	if err := NewRedis(client, "events", "stream", 1000).Publish(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := sub.ReceiveMessage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received Event
+	if err := json.Unmarshal([]byte(message.Payload), &received); err != nil || received.ID != event.ID {
+		t.Errorf("unexpected pub/sub payload %s (%v)", message.Payload, err)
+	}
+
+	entries, err := client.XRange(ctx, "stream", "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Values["type"] != string(AssetCommitted) {
+		t.Errorf("unexpected stream entries %+v", entries)
+	}
+}
@@ -0,0 +1,61 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory delivers events to in-process subscribers. Publish waits for slow
+// subscribers until ctx is done rather than dropping events.
+type Memory struct {
+	mu          sync.RWMutex
+	subscribers map[int]*subscription
+	next        int
+}
+
+type subscription struct {
+	ch   chan Event
+	done chan struct{} // closed first on unsubscribe to release a blocked Publish
+}
+
+func NewMemory() *Memory {
+	return &Memory{subscribers: make(map[int]*subscription)}
+}
+
+// Subscribe returns a channel receiving every event published from now on
+// and a function that ends the subscription and closes the channel.
+func (m *Memory) Subscribe(buffer int) (<-chan Event, func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.next
+	m.next++
+	sub := &subscription{ch: make(chan Event, buffer), done: make(chan struct{})}
+	m.subscribers[id] = sub
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			close(sub.done)
+			m.mu.Lock()
+			delete(m.subscribers, id)
+			m.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+}
+
+func (m *Memory) Publish(ctx context.Context, event Event) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, sub := range m.subscribers {
+		select {
+		case sub.ch <- event:
+		case <-sub.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package events
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/goccy/go-json"
+	bolt "go.etcd.io/bbolt"
+)
+
+var outboxBucket = []byte("outbox")
+
+// Outbox makes publishing durable: Publish only records the event in a bbolt
+// file, and Run relays recorded events to the target in order, deleting each
+// once the target accepted it. Events survive restarts and target outages,
+// and may be delivered more than once.
+type Outbox struct {
+	db     *bolt.DB
+	target Publisher
+	notify chan struct{}
+
+	// Failed deliveries are retried after minRetry, doubling up to maxRetry.
+	minRetry, maxRetry time.Duration
+}
+
+// OpenOutbox opens or creates the outbox file at path.
+func OpenOutbox(path string, target Publisher, minRetry, maxRetry time.Duration) (*Outbox, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("events: open %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(outboxBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Outbox{
+		db:       db,
+		target:   target,
+		notify:   make(chan struct{}, 1),
+		minRetry: minRetry,
+		maxRetry: maxRetry,
+	}, nil
+}
+
+func (o *Outbox) Close() error {
+	return o.db.Close()
+}
+
+// Publish records the event for delivery and wakes the relay.
+func (o *Outbox) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	err = o.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(outboxBucket)
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		return bucket.Put(sequenceKey(seq), payload)
+	})
+	if err != nil {
+		return fmt.Errorf("events: record %s: %w", event.Type, err)
+	}
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pending returns how many events are waiting for delivery.
+func (o *Outbox) Pending() int {
+	pending := 0
+	o.db.View(func(tx *bolt.Tx) error {
+		pending = tx.Bucket(outboxBucket).Stats().KeyN
+		return nil
+	})
+	return pending
+}
+
+// Flush delivers recorded events oldest first and returns how many were
+// delivered. It stops at the first failure so events keep their order.
+func (o *Outbox) Flush(ctx context.Context) (int, error) {
+	delivered := 0
+	for {
+		key, event, err := o.oldest()
+		if err != nil || key == nil {
+			return delivered, err
+		}
+
+		if err := o.target.Publish(ctx, event); err != nil {
+			return delivered, err
+		}
+
+		err = o.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(outboxBucket).Delete(key)
+		})
+		if err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+}
+
+// oldest returns the first recorded event, or a nil key when there is none.
+// Records that no longer decode are dropped rather than blocking the outbox.
+func (o *Outbox) oldest() ([]byte, Event, error) {
+	var key []byte
+	var event Event
+	err := o.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(outboxBucket)
+		for {
+			k, v := bucket.Cursor().First()
+			if k == nil {
+				return nil
+			}
+			err := json.Unmarshal(v, &event)
+			if err == nil {
+				key = append([]byte(nil), k...)
+				return nil
+			}
+			slog.Error("dropping undecodable outbox event", "seq", binary.BigEndian.Uint64(k), "error", err)
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+	})
+	return key, event, err
+}
+
+// Run relays events until ctx is done, retrying failed deliveries with
+// exponential backoff. Events recorded before a restart are sent first.
+func (o *Outbox) Run(ctx context.Context) {
+	retry := o.minRetry
+	for {
+		delivered, err := o.Flush(ctx)
+
+		var wait <-chan time.Time
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("event delivery failed", "delivered", delivered, "pending", o.Pending(), "retry_in", retry, "error", err)
+			wait = time.After(retry)
+			retry = min(retry*2, o.maxRetry)
+		} else {
+			retry = o.minRetry
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.notify:
+			if wait != nil {
+				// Keep backing off; new events join the queue behind the failed one.
+				select {
+				case <-ctx.Done():
+					return
+				case <-wait:
+				}
+			}
+		case <-wait:
+		}
+	}
+}
+
+func sequenceKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
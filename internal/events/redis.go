@@ -0,0 +1,50 @@
+package events
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goccy/go-json"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis publishes each event to a pub/sub channel for live listeners and
+// appends it to a stream, capped at roughly maxLen entries, for consumers
+// that need to catch up after a restart.
+type Redis struct {
+	client  redis.UniversalClient
+	channel string
+	stream  string
+	maxLen  int64
+}
+
+func NewRedis(client redis.UniversalClient, channel, stream string, maxLen int64) *Redis {
+	return &Redis{
+		client:  client,
+		channel: channel,
+		stream:  stream,
+		maxLen:  maxLen,
+	}
+}
+
+func (r *Redis) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: r.stream,
+			MaxLen: r.maxLen,
+			Approx: true,
+			Values: map[string]any{"id": event.ID.String(), "type": string(event.Type), "event": payload},
+		})
+		pipe.Publish(ctx, r.channel, payload)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis publish %s: %w", event.Type, err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package exiftool
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -11,6 +12,8 @@ import (
 	"time"
 
 	"github.com/goccy/go-json"
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+	"github.com/mahdi-cpp/upload-service/internal/metrics"
 )
 
 type ExifTool struct {
@@ -37,10 +40,12 @@ func (et *ExifTool) SaveMetadata(me *Metadata, path string) error {
 	return os.Rename(tempFile, path)
 }
 
-func (et *ExifTool) GetMetadata(filename string) (*Metadata, error) {
+func (et *ExifTool) GetMetadata(ctx context.Context, filename string) (*Metadata, error) {
 
 	// First, let's see what keys are available by running without groups
-	cmd := exec.Command(et.exiftoolPath, "-j", "-c", "%.6f", filename)
+	cmd := exec.CommandContext(ctx, et.exiftoolPath, "-j", "-c", "%.6f", filename)
+	metrics.Spawned("exiftool")
+	logging.FromContext(ctx).Debug("running exiftool", "file", filename)
 
 	output, err := cmd.Output()
 	if err != nil {
@@ -82,6 +87,10 @@ func (et *ExifTool) parseMetadata(filename string, rawData map[string]interface{
 	if strings.Contains(metadata.FileInfo.MimeType, "video") {
 		// Parse VideoInfo
 		metadata.Video = et.parseVideoInfo(rawData)
+	} else if metadata.FileInfo.MimeType == "application/pdf" {
+		metadata.Document = et.parseDocumentInfo(rawData)
+	} else if strings.HasPrefix(metadata.FileInfo.MimeType, "audio/") {
+		metadata.Audio = et.parseAudioInfo(rawData)
 	} else {
 		// Parse ImageInfo
 		metadata.Image = et.parseImageInfo(rawData)
@@ -93,6 +102,8 @@ func (et *ExifTool) parseMetadata(filename string, rawData map[string]interface{
 	// Parse Location
 	metadata.Location = et.parseLocationInfo(rawData)
 
+	metadata.Motion = et.parseMotionInfo(rawData)
+
 	return metadata
 }
 
@@ -165,10 +176,22 @@ func (et *ExifTool) parseImageInfo(rawData map[string]interface{}) ImageInfo {
 		imageInfo.ColorSpace = colorSpace
 	}
 
+	if profile, ok := getString(rawData, "ProfileDescription"); ok {
+		imageInfo.ColorProfile = profile
+	}
+
 	if encodingProcess, ok := getString(rawData, "EncodingProcess"); ok {
 		imageInfo.EncodingProcess = encodingProcess
 	}
 
+	// GIF only; other animated formats are filled in from libvips.
+	if frames, ok := getInt(rawData, "FrameCount"); ok && frames > 1 {
+		imageInfo.FrameCount = frames
+		if loops, ok := getInt(rawData, "AnimationIterations"); ok {
+			imageInfo.LoopCount = loops // "Infinite" doesn't parse and stays 0
+		}
+	}
+
 	return imageInfo
 }
 
@@ -176,7 +199,7 @@ func (et *ExifTool) parseVideoInfo(rawData map[string]interface{}) VideoInfo {
 	videoInfo := VideoInfo{}
 
 	if duration, ok := getString(rawData, "Duration", "MediaDuration"); ok {
-		videoInfo.MediaDuration = duration
+		videoInfo.Duration = parseDuration(duration)
 	}
 
 	if width, ok := getInt(rawData, "VideoWidth", "ImageWidth"); ok {
@@ -192,7 +215,7 @@ func (et *ExifTool) parseVideoInfo(rawData map[string]interface{}) VideoInfo {
 	}
 
 	if bitrate, ok := getString(rawData, "AvgBitrate", "Bitrate"); ok {
-		videoInfo.AvgBitrate = bitrate
+		videoInfo.Bitrate = parseBitrate(bitrate)
 	}
 
 	if encoder, ok := getString(rawData, "Encoder"); ok {
@@ -290,6 +313,154 @@ func (et *ExifTool) parseLocationInfo(rawData map[string]interface{}) Location {
 	return location
 }
 
+func (et *ExifTool) parseDocumentInfo(rawData map[string]interface{}) DocumentInfo {
+	documentInfo := DocumentInfo{}
+
+	if pages, ok := getInt(rawData, "PageCount"); ok {
+		documentInfo.PageCount = pages
+	}
+
+	if title, ok := getString(rawData, "Title"); ok {
+		documentInfo.Title = title
+	}
+
+	if author, ok := getString(rawData, "Author"); ok {
+		documentInfo.Author = author
+	}
+
+	if subject, ok := getString(rawData, "Subject"); ok {
+		documentInfo.Subject = subject
+	}
+
+	if creator, ok := getString(rawData, "Creator"); ok {
+		documentInfo.Creator = creator
+	}
+
+	if producer, ok := getString(rawData, "Producer"); ok {
+		documentInfo.Producer = producer
+	}
+
+	if version, ok := getString(rawData, "PDFVersion"); ok {
+		documentInfo.Version = version
+	} else if version, ok := getFloat(rawData, "PDFVersion"); ok {
+		documentInfo.Version = strconv.FormatFloat(version, 'f', -1, 64)
+	}
+
+	return documentInfo
+}
+
+func (et *ExifTool) parseAudioInfo(rawData map[string]interface{}) AudioInfo {
+	audioInfo := AudioInfo{}
+
+	if duration, ok := getString(rawData, "Duration"); ok {
+		audioInfo.Duration = parseDuration(duration)
+	}
+
+	// M4A names its codec; the container names it for the rest.
+	if codec, ok := getString(rawData, "AudioFormat", "FileType"); ok {
+		audioInfo.Codec = codec
+	}
+
+	if bitrate, ok := getString(rawData, "AudioBitrate", "AvgBitrate", "NominalBitrate"); ok {
+		audioInfo.Bitrate = parseBitrate(bitrate)
+	}
+
+	if sampleRate, ok := getInt(rawData, "SampleRate", "AudioSampleRate"); ok {
+		audioInfo.SampleRate = sampleRate
+	}
+
+	if channels, ok := getInt(rawData, "AudioChannels", "Channels", "NumChannels"); ok {
+		audioInfo.Channels = channels
+	} else if mode, ok := getString(rawData, "ChannelMode"); ok {
+		// MP3 reports a mode rather than a count.
+		audioInfo.Channels = 2
+		if mode == "Single Channel" {
+			audioInfo.Channels = 1
+		}
+	}
+
+	if bits, ok := getInt(rawData, "BitsPerSample", "AudioBitsPerSample"); ok {
+		audioInfo.BitsPerSample = bits
+	}
+
+	if title, ok := getString(rawData, "Title"); ok {
+		audioInfo.Title = title
+	}
+
+	if artist, ok := getString(rawData, "Artist"); ok {
+		audioInfo.Artist = artist
+	}
+
+	if album, ok := getString(rawData, "Album"); ok {
+		audioInfo.Album = album
+	}
+
+	if albumArtist, ok := getString(rawData, "AlbumArtist", "Band"); ok {
+		audioInfo.AlbumArtist = albumArtist
+	}
+
+	if genre, ok := getString(rawData, "Genre"); ok {
+		audioInfo.Genre = genre
+	}
+
+	if year, ok := getString(rawData, "Year", "Date", "ContentCreateDate"); ok {
+		audioInfo.Year = year
+	} else if year, ok := getInt(rawData, "Year"); ok {
+		audioInfo.Year = strconv.Itoa(year)
+	}
+
+	if track, ok := getString(rawData, "Track", "TrackNumber"); ok {
+		audioInfo.Track = track
+	} else if track, ok := getInt(rawData, "Track", "TrackNumber"); ok {
+		audioInfo.Track = strconv.Itoa(track)
+	}
+
+	return audioInfo
+}
+
+func (et *ExifTool) parseMotionInfo(rawData map[string]interface{}) MotionInfo {
+	motion := MotionInfo{}
+
+	if id, ok := getString(rawData, "ContentIdentifier", "MediaGroupUUID"); ok {
+		motion.ContentIdentifier = id
+	}
+
+	// Google's older MicroVideo XMP gives the video's offset from the end.
+	if flag, ok := getInt(rawData, "MicroVideo"); ok && flag == 1 {
+		motion.Embedded = true
+		if offset, ok := getInt(rawData, "MicroVideoOffset"); ok {
+			motion.VideoLength = int64(offset)
+		}
+		if ts, ok := getInt(rawData, "MicroVideoPresentationTimestampUs"); ok {
+			motion.PresentationTimestampUs = int64(ts)
+		}
+	}
+
+	// The MotionPhoto format lists the appended items in a container directory.
+	if flag, ok := getInt(rawData, "MotionPhoto"); ok && flag == 1 {
+		motion.Embedded = true
+		semantics := getStrings(rawData, "DirectoryItemSemantic")
+		lengths := getStrings(rawData, "DirectoryItemLength")
+		for i, semantic := range semantics {
+			if semantic == "MotionPhoto" && i < len(lengths) {
+				if length, err := strconv.ParseInt(lengths[i], 10, 64); err == nil {
+					motion.VideoLength = length
+				}
+			}
+		}
+		if ts, ok := getInt(rawData, "MotionPhotoPresentationTimestampUs"); ok {
+			motion.PresentationTimestampUs = int64(ts)
+		}
+	}
+
+	// Samsung appends the video after a "MotionPhoto_Data" trailer.
+	if videoType, ok := getString(rawData, "EmbeddedVideoType"); ok && strings.Contains(videoType, "MotionPhoto") {
+		motion.Embedded = true
+	}
+
+	return motion
+}
+
 // Helper functions
 func getString(data map[string]interface{}, keys ...string) (string, bool) {
 	for _, key := range keys {
@@ -302,6 +473,22 @@ func getString(data map[string]interface{}, keys ...string) (string, bool) {
 	return "", false
 }
 
+// getStrings reads a list tag, which exiftool writes as an array, or as a
+// single value when the list has one entry.
+func getStrings(data map[string]interface{}, key string) []string {
+	var values []string
+	switch v := data[key].(type) {
+	case []interface{}:
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+	case nil:
+	default:
+		values = append(values, fmt.Sprint(v))
+	}
+	return values
+}
+
 func getInt(data map[string]interface{}, keys ...string) (int, bool) {
 	for _, key := range keys {
 		if value, ok := data[key]; ok {
@@ -341,3 +528,40 @@ func getFloat(data map[string]interface{}, keys ...string) (float64, bool) {
 	}
 	return 0, false
 }
+
+// parseBitrate reads exiftool's bitrates, "128 kbps" or "1.41 Mbps", as bits
+// per second. It returns 0 when the value isn't recognised.
+func parseBitrate(value string) int {
+	number, unit, _ := strings.Cut(strings.TrimSpace(value), " ")
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0
+	}
+	switch strings.ToLower(unit) {
+	case "kbps":
+		n *= 1e3
+	case "mbps":
+		n *= 1e6
+	}
+	return int(n)
+}
+
+// parseDuration reads exiftool's duration formats, "12.34 s" and "0:01:23",
+// as seconds. It returns 0 when the value isn't recognised.
+func parseDuration(value string) float64 {
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "(approx)"))
+	if seconds, ok := strings.CutSuffix(value, " s"); ok {
+		d, _ := strconv.ParseFloat(seconds, 64)
+		return d
+	}
+
+	var total float64
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		total = total*60 + n
+	}
+	return total
+}
@@ -1,6 +1,7 @@
 package exiftool
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"testing"
@@ -13,7 +14,7 @@ func TestNewExif(t *testing.T) {
 	exifTool := NewExifTool()
 
 	// Example with image file
-	imageMetadata, err := exifTool.GetMetadata("/app/tmp/test.jpg")
+	imageMetadata, err := exifTool.GetMetadata(context.Background(), "/app/tmp/test.jpg")
 	if err != nil {
 		log.Fatalf("Error reading image metadata: %v", err)
 	}
@@ -10,6 +10,9 @@ type Metadata struct {
 	Camera           CameraInfo             `json:"camera,omitempty"`
 	Video            VideoInfo              `json:"video,omitempty"`
 	Location         Location               `json:"location,omitempty"`
+	Motion           MotionInfo             `json:"motion,omitempty"`
+	Document         DocumentInfo           `json:"document,omitempty"`
+	Audio            AudioInfo              `json:"audio,omitempty"`
 	DateTimeOriginal time.Time              `json:"dateTimeOriginal,omitempty"`
 	RawData          map[string]interface{} `json:"-"` // Raw EXIF data for debugging
 }
@@ -28,7 +31,13 @@ type ImageInfo struct {
 	Megapixels      float64 `json:"megapixels,omitempty"`
 	Orientation     string  `json:"orientation,omitempty"`
 	ColorSpace      string  `json:"colorSpace,omitempty"`
+	ColorProfile    string  `json:"colorProfile,omitempty"` // ICC description, e.g. "Display P3"
 	EncodingProcess string  `json:"encodingProcess,omitempty"`
+
+	// Animated images only.
+	FrameCount        int     `json:"frameCount,omitempty"`
+	LoopCount         int     `json:"loopCount,omitempty"`         // 0 loops forever
+	AnimationDuration float64 `json:"animationDuration,omitempty"` // seconds per play through
 }
 
 type CameraInfo struct {
@@ -46,18 +55,45 @@ type CameraInfo struct {
 	WhiteBalance    string  `json:"whiteBalance,omitempty"`
 }
 
+// VideoInfo is filled from exiftool, then from ffprobe where it knows better;
+// see ffmpeg.Probe.
 type VideoInfo struct {
-	MediaDuration      string  `json:"mediaDuration,omitempty"`
-	Width              int     `json:"width,omitempty"`
-	Height             int     `json:"height,omitempty"`
-	VideoFrameRate     float64 `json:"videoFrameRate,omitempty"`
-	AvgBitrate         string  `json:"avgBitrate,omitempty"`
-	Encoder            string  `json:"encoder,omitempty"`
-	Rotation           int     `json:"rotation,omitempty"`
-	AudioFormat        string  `json:"audioFormat,omitempty"`
-	AudioChannels      int     `json:"audioChannels,omitempty"`
-	AudioSampleRate    int     `json:"audioSampleRate,omitempty"`
-	AudioBitsPerSample int     `json:"audioBitsPerSample,omitempty"`
+	Duration       float64 `json:"duration,omitempty"` // seconds
+	Width          int     `json:"width,omitempty"`
+	Height         int     `json:"height,omitempty"`
+	VideoFrameRate float64 `json:"videoFrameRate,omitempty"`
+	FrameRate      string  `json:"frameRate,omitempty"` // exact, e.g. "30000/1001"
+	Bitrate        int     `json:"bitrate,omitempty"`   // bits per second
+	Encoder        string  `json:"encoder,omitempty"`
+	Rotation       int     `json:"rotation,omitempty"` // degrees clockwise to display upright
+
+	Codec       string `json:"codec,omitempty"`
+	Profile     string `json:"profile,omitempty"`
+	PixelFormat string `json:"pixelFormat,omitempty"`
+	BitDepth    int    `json:"bitDepth,omitempty"`
+
+	// HDR video is tagged "smpte2084" (PQ) or "arib-std-b67" (HLG), usually
+	// with "bt2020" primaries.
+	HDR            string `json:"hdr,omitempty"` // "pq" or "hlg"
+	ColorTransfer  string `json:"colorTransfer,omitempty"`
+	ColorPrimaries string `json:"colorPrimaries,omitempty"`
+	ColorSpace     string `json:"colorSpace,omitempty"`
+
+	AudioFormat        string `json:"audioFormat,omitempty"`
+	AudioChannels      int    `json:"audioChannels,omitempty"`
+	AudioChannelLayout string `json:"audioChannelLayout,omitempty"` // e.g. "stereo", "5.1"
+	AudioSampleRate    int    `json:"audioSampleRate,omitempty"`
+	AudioBitsPerSample int    `json:"audioBitsPerSample,omitempty"`
+
+	Subtitles []SubtitleTrack `json:"subtitles,omitempty"`
+}
+
+type SubtitleTrack struct {
+	Codec    string `json:"codec,omitempty"`
+	Language string `json:"language,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Default  bool   `json:"default,omitempty"`
+	Forced   bool   `json:"forced,omitempty"`
 }
 
 type Location struct {
@@ -70,3 +106,55 @@ type Location struct {
 	Village    string  `json:"village,omitempty"`
 	Electronic int     `json:"electronic,omitempty"`
 }
+
+// MotionInfo identifies live and motion photos.
+type MotionInfo struct {
+	// ContentIdentifier pairs an Apple Live Photo: the HEIC/JPEG still and
+	// the MOV carry the same value.
+	ContentIdentifier string `json:"contentIdentifier,omitempty"`
+
+	// Embedded is set for Google and Samsung motion photos, which append an
+	// MP4 to the still image.
+	Embedded bool `json:"embedded,omitempty"`
+
+	// VideoLength is the size of the embedded video counted from the end of
+	// the file, when the file states it (MicroVideoOffset or the container
+	// directory); 0 means it has to be searched for.
+	VideoLength int64 `json:"videoLength,omitempty"`
+
+	// PresentationTimestampUs is the video frame the still was taken from.
+	PresentationTimestampUs int64 `json:"presentationTimestampUs,omitempty"`
+}
+
+// DocumentInfo describes a PDF.
+type DocumentInfo struct {
+	PageCount int    `json:"pageCount,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Author    string `json:"author,omitempty"`
+	Subject   string `json:"subject,omitempty"`
+	Creator   string `json:"creator,omitempty"`  // application that created the original
+	Producer  string `json:"producer,omitempty"` // application that wrote the PDF
+	Version   string `json:"version,omitempty"`  // PDF version, e.g. "1.7"
+}
+
+// AudioInfo describes an audio file, with its ID3, iTunes or Vorbis tags.
+type AudioInfo struct {
+	Duration      float64 `json:"duration,omitempty"` // seconds
+	Codec         string  `json:"codec,omitempty"`
+	Bitrate       int     `json:"bitrate,omitempty"` // bits per second
+	SampleRate    int     `json:"sampleRate,omitempty"`
+	Channels      int     `json:"channels,omitempty"`
+	BitsPerSample int     `json:"bitsPerSample,omitempty"`
+
+	Title       string `json:"title,omitempty"`
+	Artist      string `json:"artist,omitempty"`
+	Album       string `json:"album,omitempty"`
+	AlbumArtist string `json:"albumArtist,omitempty"`
+	Genre       string `json:"genre,omitempty"`
+	Year        string `json:"year,omitempty"`
+	Track       string `json:"track,omitempty"`
+
+	// Peaks is the waveform, filled in by the upload service rather than
+	// exiftool: loudness from 0 to 100 across the recording.
+	Peaks []int `json:"peaks,omitempty"`
+}
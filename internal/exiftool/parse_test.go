@@ -0,0 +1,32 @@
+package exiftool
+
+import "testing"
+
+func TestParseVideoInfo(t *testing.T) {
+
+	info := NewExifTool().parseVideoInfo(map[string]interface{}{
+		"Duration":   "0:01:23",
+		"AvgBitrate": "12.3 Mbps",
+		"ImageWidth": float64(1920),
+	})
+
+	if info.Duration != 83 || info.Bitrate != 12300000 || info.Width != 1920 {
+		t.Errorf("unexpected video info %+v", info)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	for value, want := range map[string]float64{"12.5 s": 12.5, "0:01:23": 83, "3.1 s (approx)": 3.1, "soon": 0} {
+		if got := parseDuration(value); got != want {
+			t.Errorf("parseDuration(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestParseBitrate(t *testing.T) {
+	for value, want := range map[string]int{"128 kbps": 128000, "1.41 Mbps": 1410000, "": 0, "fast": 0} {
+		if got := parseBitrate(value); got != want {
+			t.Errorf("parseBitrate(%q) = %d, want %d", value, got, want)
+		}
+	}
+}
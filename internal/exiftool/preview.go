@@ -0,0 +1,97 @@
+package exiftool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+	"github.com/mahdi-cpp/upload-service/internal/metrics"
+)
+
+// ErrNoPreview is returned when a RAW file has no embedded JPEG.
+var ErrNoPreview = errors.New("no embedded preview")
+
+// ErrNoCoverArt is returned when an audio file has no embedded picture.
+var ErrNoCoverArt = errors.New("no cover art")
+
+// previewTags are the tags cameras store JPEG previews under. Most RAW
+// formats carry more than one; the largest is used.
+var previewTags = []string{"JpgFromRaw", "PreviewImage", "OtherImage"}
+
+// coverArtTags hold the picture in ID3 and FLAC (Picture) and M4A (CoverArt).
+var coverArtTags = []string{"Picture", "CoverArt"}
+
+// ExtractPreview writes the largest JPEG embedded in the RAW file at rawPath
+// to outPath, then copies the RAW's orientation onto it: previews are stored
+// unrotated and rarely carry the tag themselves.
+func (et *ExifTool) ExtractPreview(ctx context.Context, rawPath, outPath string) error {
+
+	preview, err := et.largestBinary(ctx, rawPath, previewTags, func(data []byte) bool {
+		return bytes.HasPrefix(data, []byte{0xFF, 0xD8})
+	})
+	if err != nil {
+		return err
+	}
+	if preview == nil {
+		return ErrNoPreview
+	}
+
+	if err := os.WriteFile(outPath, preview, 0644); err != nil {
+		return fmt.Errorf("write preview: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, et.exiftoolPath, "-overwrite_original", "-TagsFromFile", rawPath, "-Orientation", outPath)
+	metrics.Spawned("exiftool")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("copy orientation: %s", bytes.TrimSpace(output))
+	}
+
+	logging.FromContext(ctx).Debug("extracted raw preview", "file", rawPath, "bytes", len(preview))
+	return nil
+}
+
+// ExtractCoverArt writes the picture embedded in the audio file at audioPath
+// to outPath as it is stored, usually JPEG or PNG.
+func (et *ExifTool) ExtractCoverArt(ctx context.Context, audioPath, outPath string) error {
+
+	picture, err := et.largestBinary(ctx, audioPath, coverArtTags, func(data []byte) bool {
+		return len(data) > 0
+	})
+	if err != nil {
+		return err
+	}
+	if picture == nil {
+		return ErrNoCoverArt
+	}
+
+	if err := os.WriteFile(outPath, picture, 0644); err != nil {
+		return fmt.Errorf("write cover art: %w", err)
+	}
+	logging.FromContext(ctx).Debug("extracted cover art", "file", audioPath, "bytes", len(picture))
+	return nil
+}
+
+// largestBinary reads each of tags from path as binary and returns the
+// largest value accept allows, or nil when there is none.
+func (et *ExifTool) largestBinary(ctx context.Context, path string, tags []string, accept func([]byte) bool) ([]byte, error) {
+	var largest []byte
+	for _, tag := range tags {
+		cmd := exec.CommandContext(ctx, et.exiftoolPath, "-b", "-"+tag, path)
+		metrics.Spawned("exiftool")
+		output, err := cmd.Output()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue // a missing tag is not an error worth reporting
+		}
+		if accept(output) && len(output) > len(largest) {
+			largest = output
+		}
+	}
+	return largest, nil
+}
@@ -0,0 +1,99 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+	"github.com/mahdi-cpp/upload-service/internal/metrics"
+)
+
+// AudioCodec is a target for TranscodeAudio.
+type AudioCodec string
+
+const (
+	CodecOpus AudioCodec = "opus" // Ogg Opus, for voice messages
+	CodecAAC  AudioCodec = "aac"  // AAC in an M4A container, for Apple clients
+)
+
+// audioEncoders maps codecs to ffmpeg encoder arguments.
+var audioEncoders = map[AudioCodec][]string{
+	CodecOpus: {"-c:a", "libopus", "-f", "ogg"},
+	CodecAAC:  {"-c:a", "aac", "-f", "ipod"},
+}
+
+// DecodePCM streams the first audio track of inputPath to w as mono signed
+// 16-bit little-endian samples at sampleRate Hz.
+//
+// The command used is:
+// ffmpeg -i <inputPath> -vn -ac 1 -ar <sampleRate> -f s16le -
+func DecodePCM(ctx context.Context, inputPath string, sampleRate int, w io.Writer) error {
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return fmt.Errorf("ffmpeg not found in PATH: %w", err)
+	}
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", inputPath,
+		"-vn",
+		"-ac", "1",
+		"-ar", strconv.Itoa(sampleRate),
+		"-f", "s16le",
+		"-",
+	}
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	metrics.Spawned("ffmpeg")
+
+	var stderr bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	logging.FromContext(ctx).Debug("running ffmpeg", "args", args)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg decode audio: %w: %s", err, tail(stderr.String()))
+	}
+	return nil
+}
+
+// TranscodeAudio re-encodes the first audio track of inputPath as codec at
+// bitrate bits per second, dropping any video or cover art.
+func TranscodeAudio(ctx context.Context, inputPath, outputPath string, codec AudioCodec, bitrate int) error {
+	encoder, ok := audioEncoders[codec]
+	if !ok {
+		return fmt.Errorf("ffmpeg: unsupported audio codec %q", codec)
+	}
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return fmt.Errorf("ffmpeg not found in PATH: %w", err)
+	}
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", inputPath,
+		"-vn",
+		"-map_metadata", "0",
+	}
+	args = append(args, encoder...)
+	args = append(args, "-b:a", strconv.Itoa(bitrate), "-y", outputPath)
+
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	metrics.Spawned("ffmpeg")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	logging.FromContext(ctx).Debug("running ffmpeg", "args", args)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg transcode audio: %w: %s", err, tail(stderr.String()))
+	}
+
+	logging.FromContext(ctx).Debug("transcoded audio", "output", outputPath, "codec", codec)
+	return nil
+}
@@ -1,53 +1,93 @@
 package ffmpeg
 
 import (
-	"log"
-	"os"
+	"bytes"
+	"context"
+	"fmt"
 	"os/exec"
+	"strings"
+
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+	"github.com/mahdi-cpp/upload-service/internal/metrics"
 )
 
+// maxStderr bounds how much of ffmpeg's diagnostic output is kept for errors.
+const maxStderr = 4 << 10
+
+// toneMapFilter maps HDR (PQ or HLG) video to SDR BT.709, so frames taken
+// from it aren't washed out. It needs ffmpeg built with zimg.
+const toneMapFilter = "zscale=t=linear:npl=100,format=gbrpf32le,zscale=p=bt709," +
+	"tonemap=tonemap=hable:desat=0,zscale=t=bt709:m=bt709:r=tv,format=yuv420p"
+
+// videoFilter prefixes filter with tone mapping when toneMap is set.
+func videoFilter(toneMap bool, filter string) string {
+	if !toneMap {
+		return filter
+	}
+	return toneMapFilter + "," + filter
+}
+
 // ExtractFrame extracts a single frame from an input video at a specified timestamp
-// and saves it to the given output path.
+// and saves it to the given output path. HDR video should be tone mapped;
+// when this ffmpeg can't, the frame is extracted as it is.
 //
 // The command used is:
 // ffmpeg -ss 00:01:30 -i <inputPath> -vframes 1 -q:v 2 -vf "scale=1280:-1" <outputPath>
-func ExtractFrame(inputPath, outputPath string) error {
+func ExtractFrame(ctx context.Context, inputPath, outputPath string, toneMap bool) error {
+	err := extractFrame(ctx, inputPath, outputPath, toneMap)
+	if err != nil && toneMap && ctx.Err() == nil {
+		logging.FromContext(ctx).Warn("failed to tone map frame, extracting it as it is", "error", err)
+		err = extractFrame(ctx, inputPath, outputPath, false)
+	}
+	return err
+}
+
+func extractFrame(ctx context.Context, inputPath, outputPath string, toneMap bool) error {
+	logger := logging.FromContext(ctx)
+
 	// First, check if the ffmpeg executable is available in the system's PATH.
 	ffmpegPath, err := exec.LookPath("ffmpeg")
 	if err != nil {
-		log.Printf("Error: ffmpeg not found. Please ensure it is installed and available in your system's PATH.")
-		return err
+		return fmt.Errorf("ffmpeg not found in PATH: %w", err)
 	}
 
 	// The command and its arguments are defined as a slice of strings.
 	// This is the standard and safest way to pass arguments to an external command.
 	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
 		"-ss", "00:00:5",
 		"-i", inputPath,
 		"-vframes", "1",
 		"-q:v", "2",
-		"-vf", "scale=1280:-1",
+		"-vf", videoFilter(toneMap, "scale=1280:-1"),
 		outputPath,
 	}
 
 	// Create a new command object.
-	cmd := exec.Command(ffmpegPath, args...)
-
-	// Optional: Set a specific working directory for the command.
-	// cmd.Dir = "/path/to/your/directory"
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	metrics.Spawned("ffmpeg")
 
-	// Set the command's output to the standard logger for debugging purposes.
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	// Keep stderr for the error instead of mixing it into the service output.
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 
-	log.Printf("Executing command: %s %s", cmd.Path, args)
+	logger.Debug("running ffmpeg", "args", args)
 
 	// Run the command and return any error.
 	if err := cmd.Run(); err != nil {
-		log.Printf("Error executing ffmpeg command: %v", err)
-		return err
+		return fmt.Errorf("ffmpeg extract frame: %w: %s", err, tail(stderr.String()))
 	}
 
-	log.Printf("Successfully extracted frame to %s", outputPath)
+	logger.Debug("extracted frame", "output", outputPath)
 	return nil
 }
+
+// tail returns the last maxStderr bytes of output, trimmed of whitespace.
+func tail(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > maxStderr {
+		output = "..." + output[len(output)-maxStderr:]
+	}
+	return output
+}
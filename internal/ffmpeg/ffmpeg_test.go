@@ -1,6 +1,8 @@
 package ffmpeg
 
 import (
+	"context"
+	"strings"
 	"testing"
 )
 
@@ -10,7 +12,29 @@ func TestExtractFrame(t *testing.T) {
 	outputImage := "/app/tmp/video_cover5.jpg"
 
 	// Call the function with the desired file paths.
-	if err := ExtractFrame(inputVideo, outputImage); err != nil {
+	if err := ExtractFrame(context.Background(), inputVideo, outputImage, false); err != nil {
 		t.Errorf("Failed to extract frame: %v", err)
 	}
 }
+
+func TestTail(t *testing.T) {
+
+	if got := tail("  boom\n"); got != "boom" {
+		t.Errorf("tail = %q, want %q", got, "boom")
+	}
+
+	long := strings.Repeat("a", maxStderr) + "end"
+	if got := tail(long); len(got) != maxStderr+3 || !strings.HasSuffix(got, "end") {
+		t.Errorf("tail should keep the last %d bytes", maxStderr)
+	}
+}
+
+func TestVideoFilter(t *testing.T) {
+
+	if got := videoFilter(false, "scale=1280:-1"); got != "scale=1280:-1" {
+		t.Errorf("videoFilter(false) = %q", got)
+	}
+	if got := videoFilter(true, "scale=1280:-1"); !strings.HasPrefix(got, toneMapFilter+",") || !strings.HasSuffix(got, ",scale=1280:-1") {
+		t.Errorf("videoFilter(true) should tone map before scaling: %q", got)
+	}
+}
@@ -0,0 +1,173 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+	"github.com/mahdi-cpp/upload-service/internal/metrics"
+)
+
+// ErrPreviewTooLarge is returned when a preview clip comes out over its size cap.
+var ErrPreviewTooLarge = errors.New("preview clip too large")
+
+// PreviewOptions controls the short, muted loop made for gallery tiles.
+type PreviewOptions struct {
+	Length   time.Duration
+	Width    int   // pixels; the height follows the video's aspect ratio
+	FPS      int   // frames per second
+	MaxBytes int64 // the clip is refused when larger
+	ToneMap  bool  // for HDR video
+}
+
+// previewEncoders maps output extensions to ffmpeg encoder arguments.
+var previewEncoders = map[string][]string{
+	".mp4":  {"-c:v", "libx264", "-profile:v", "main", "-pix_fmt", "yuv420p", "-crf", "30", "-movflags", "+faststart"},
+	".webp": {"-c:v", "libwebp_anim", "-q:v", "60", "-loop", "0"},
+}
+
+// PreviewClip encodes opts.Length of the video at inputPath from start as a
+// silent clip at outputPath, an MP4 or an animated WebP by its extension.
+//
+// The command used is:
+// ffmpeg -ss <start> -t <length> -i <inputPath> -an -vf "fps=<fps>,scale=<w>:-2" <encoder> -maxrate <rate> -bufsize <2*rate> <outputPath>
+func PreviewClip(ctx context.Context, inputPath, outputPath string, start time.Duration, opts PreviewOptions) error {
+	encoder, ok := previewEncoders[filepath.Ext(outputPath)]
+	if !ok {
+		return fmt.Errorf("ffmpeg: unsupported preview format %q", filepath.Ext(outputPath))
+	}
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return fmt.Errorf("ffmpeg not found in PATH: %w", err)
+	}
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-ss", seconds(start),
+		"-t", seconds(opts.Length),
+		"-i", inputPath,
+		"-an",
+		"-vf", videoFilter(opts.ToneMap, fmt.Sprintf("fps=%d,scale=%d:-2", opts.FPS, opts.Width)),
+	}
+	args = append(args, encoder...)
+	if opts.MaxBytes > 0 && opts.Length > 0 {
+		// Aim a little under the cap; the container adds its own bytes.
+		rate := int64(float64(opts.MaxBytes*8) / opts.Length.Seconds() * 0.9)
+		args = append(args, "-maxrate", strconv.FormatInt(rate, 10), "-bufsize", strconv.FormatInt(2*rate, 10))
+	}
+	args = append(args, "-y", outputPath)
+
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	metrics.Spawned("ffmpeg")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	logging.FromContext(ctx).Debug("running ffmpeg", "args", args)
+	if err := cmd.Run(); err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("ffmpeg preview clip: %w: %s", err, tail(stderr.String()))
+	}
+
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return fmt.Errorf("ffmpeg preview clip: %w", err)
+	}
+	if opts.MaxBytes > 0 && info.Size() > opts.MaxBytes {
+		os.Remove(outputPath)
+		return fmt.Errorf("%w: %d bytes", ErrPreviewTooLarge, info.Size())
+	}
+
+	logging.FromContext(ctx).Debug("created preview clip", "output", outputPath, "start", start, "bytes", info.Size())
+	return nil
+}
+
+// InterestingStart picks where a preview clip of length should start: at the
+// keyframe with the biggest scene change that leaves room for the whole clip.
+// Only keyframes are decoded, so this stays quick on long videos. Videos no
+// longer than the clip start at 0.
+//
+// The command used is:
+// ffmpeg -skip_frame nokey -i <inputPath> -an -vf "scale=160:-2,select='gte(scene,0)',metadata=print:file=-" -f null -
+func InterestingStart(ctx context.Context, inputPath string, duration, length time.Duration) (time.Duration, error) {
+	if duration <= length {
+		return 0, nil
+	}
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return 0, fmt.Errorf("ffmpeg not found in PATH: %w", err)
+	}
+
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-skip_frame", "nokey",
+		"-i", inputPath,
+		"-an",
+		"-vf", "scale=160:-2,select='gte(scene,0)',metadata=print:file=-",
+		"-f", "null",
+		"-",
+	}
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	metrics.Spawned("ffmpeg")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	logging.FromContext(ctx).Debug("running ffmpeg", "args", args)
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("ffmpeg scene scores: %w: %s", err, tail(stderr.String()))
+	}
+	return pickStart(parseSceneScores(stdout.Bytes()), duration, length), nil
+}
+
+type sceneScore struct {
+	At    time.Duration
+	Score float64
+}
+
+// parseSceneScores reads the metadata filter's output, a "frame:... pts_time:<t>"
+// line followed by that frame's "lavfi.scene_score=<s>".
+func parseSceneScores(output []byte) []sceneScore {
+	var scores []sceneScore
+	var at time.Duration
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if _, ptsTime, found := strings.Cut(line, "pts_time:"); found {
+			t, _ := strconv.ParseFloat(strings.TrimSpace(ptsTime), 64)
+			at = time.Duration(t * float64(time.Second))
+		} else if value, found := strings.CutPrefix(line, "lavfi.scene_score="); found {
+			score, _ := strconv.ParseFloat(value, 64)
+			scores = append(scores, sceneScore{At: at, Score: score})
+		}
+	}
+	return scores
+}
+
+// pickStart is the time of the highest scoring scene change that leaves
+// length before the end, or 0 when none does.
+func pickStart(scores []sceneScore, duration, length time.Duration) time.Duration {
+	var best sceneScore
+	for _, s := range scores {
+		if s.At+length <= duration && s.Score > best.Score {
+			best = s
+		}
+	}
+	return best.At
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
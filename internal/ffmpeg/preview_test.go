@@ -0,0 +1,41 @@
+package ffmpeg
+
+import (
+	"testing"
+	"time"
+)
+
+const sceneOutput = `frame:0    pts:0       pts_time:0
+lavfi.scene_score=0.000000
+frame:1    pts:61440   pts_time:4
+lavfi.scene_score=0.412000
+frame:2    pts:122880  pts_time:8
+lavfi.scene_score=0.080000
+frame:3    pts:168960  pts_time:11
+lavfi.scene_score=0.910000
+`
+
+func TestParseSceneScores(t *testing.T) {
+
+	scores := parseSceneScores([]byte(sceneOutput))
+
+	if len(scores) != 4 || scores[1] != (sceneScore{At: 4 * time.Second, Score: 0.412}) {
+		t.Errorf("unexpected scores %+v", scores)
+	}
+}
+
+func TestPickStart(t *testing.T) {
+
+	scores := parseSceneScores([]byte(sceneOutput))
+
+	// The cut at 11 s scores highest but leaves no room for 3 s of a 12 s video.
+	if got := pickStart(scores, 12*time.Second, 3*time.Second); got != 4*time.Second {
+		t.Errorf("pickStart = %v, want 4s", got)
+	}
+	if got := pickStart(scores, 20*time.Second, 3*time.Second); got != 11*time.Second {
+		t.Errorf("pickStart = %v, want 11s", got)
+	}
+	if got := pickStart(nil, 20*time.Second, 3*time.Second); got != 0 {
+		t.Errorf("pickStart without scores = %v, want 0", got)
+	}
+}
@@ -0,0 +1,328 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-json"
+	"github.com/mahdi-cpp/upload-service/internal/exiftool"
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+	"github.com/mahdi-cpp/upload-service/internal/metrics"
+)
+
+// Rational is an exact rate such as 30000/1001.
+type Rational struct {
+	Num, Den int
+}
+
+// parseRational reads ffprobe's "30000/1001". "0/0" and malformed values
+// are not ok.
+func parseRational(value string) (Rational, bool) {
+	num, den, found := strings.Cut(value, "/")
+	if !found {
+		den = "1"
+	}
+	n, err1 := strconv.Atoi(num)
+	d, err2 := strconv.Atoi(den)
+	if err1 != nil || err2 != nil || n <= 0 || d <= 0 {
+		return Rational{}, false
+	}
+	return Rational{Num: n, Den: d}, true
+}
+
+func (r Rational) Float64() float64 {
+	if r.Den == 0 {
+		return 0
+	}
+	return float64(r.Num) / float64(r.Den)
+}
+
+func (r Rational) String() string {
+	return strconv.Itoa(r.Num) + "/" + strconv.Itoa(r.Den)
+}
+
+// ProbeResult is what ffprobe found in a media file: the container and its
+// first video and audio streams, plus every subtitle track.
+type ProbeResult struct {
+	Format    string  // e.g. "mov,mp4,m4a,3gp,3g2,mj2" or "matroska,webm"
+	Duration  float64 // seconds
+	Bitrate   int     // bits per second, all streams
+	Video     *VideoStream
+	Audio     *AudioStream
+	Subtitles []SubtitleStream
+}
+
+type VideoStream struct {
+	Codec          string
+	Profile        string
+	PixelFormat    string
+	BitDepth       int
+	Width, Height  int
+	FrameRate      Rational
+	Bitrate        int
+	ColorTransfer  string
+	ColorPrimaries string
+	ColorSpace     string
+	Rotation       int // degrees clockwise to display upright
+}
+
+// HDR names the stream's high dynamic range transfer, "pq" (HDR10, Dolby
+// Vision) or "hlg", or is empty for SDR.
+func (v *VideoStream) HDR() string {
+	switch v.ColorTransfer {
+	case "smpte2084":
+		return "pq"
+	case "arib-std-b67":
+		return "hlg"
+	}
+	return ""
+}
+
+type AudioStream struct {
+	Codec         string
+	Profile       string
+	SampleRate    int
+	Channels      int
+	ChannelLayout string
+	BitsPerSample int
+	Bitrate       int
+}
+
+type SubtitleStream struct {
+	Codec    string
+	Language string
+	Title    string
+	Default  bool
+	Forced   bool
+}
+
+// Probe runs ffprobe on inputPath.
+//
+// The command used is:
+// ffprobe -v error -print_format json -show_format -show_streams <inputPath>
+func Probe(ctx context.Context, inputPath string) (*ProbeResult, error) {
+	ffprobePath, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe not found in PATH: %w", err)
+	}
+
+	args := []string{
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		inputPath,
+	}
+	cmd := exec.CommandContext(ctx, ffprobePath, args...)
+	metrics.Spawned("ffprobe")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	logging.FromContext(ctx).Debug("running ffprobe", "args", args)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffprobe: %w: %s", err, tail(stderr.String()))
+	}
+	return parseProbe(stdout.Bytes())
+}
+
+// probeOutput mirrors the parts of ffprobe's JSON that are used. ffprobe
+// prints most numbers as strings.
+type probeOutput struct {
+	Streams []struct {
+		CodecType        string            `json:"codec_type"`
+		CodecName        string            `json:"codec_name"`
+		Profile          string            `json:"profile"`
+		Width            int               `json:"width"`
+		Height           int               `json:"height"`
+		PixFmt           string            `json:"pix_fmt"`
+		ColorSpace       string            `json:"color_space"`
+		ColorTransfer    string            `json:"color_transfer"`
+		ColorPrimaries   string            `json:"color_primaries"`
+		AvgFrameRate     string            `json:"avg_frame_rate"`
+		RFrameRate       string            `json:"r_frame_rate"`
+		BitsPerRawSample string            `json:"bits_per_raw_sample"`
+		BitsPerSample    int               `json:"bits_per_sample"`
+		SampleRate       string            `json:"sample_rate"`
+		Channels         int               `json:"channels"`
+		ChannelLayout    string            `json:"channel_layout"`
+		BitRate          string            `json:"bit_rate"`
+		Tags             map[string]string `json:"tags"`
+		Disposition      map[string]int    `json:"disposition"`
+		SideDataList     []probeSideData   `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+type probeSideData struct {
+	SideDataType string  `json:"side_data_type"`
+	Rotation     float64 `json:"rotation"`
+}
+
+func parseProbe(output []byte) (*ProbeResult, error) {
+	var raw probeOutput
+	if err := json.Unmarshal(output, &raw); err != nil {
+		return nil, fmt.Errorf("ffprobe: parse output: %w", err)
+	}
+
+	result := &ProbeResult{Format: raw.Format.FormatName}
+	result.Duration, _ = strconv.ParseFloat(raw.Format.Duration, 64)
+	result.Bitrate, _ = strconv.Atoi(raw.Format.BitRate)
+
+	for _, s := range raw.Streams {
+		switch s.CodecType {
+		case "video":
+			// Cover art in MP4 and MKV shows up as a one-frame video stream.
+			if result.Video != nil || s.Disposition["attached_pic"] == 1 {
+				continue
+			}
+			v := &VideoStream{
+				Codec:          s.CodecName,
+				Profile:        s.Profile,
+				PixelFormat:    s.PixFmt,
+				Width:          s.Width,
+				Height:         s.Height,
+				ColorTransfer:  s.ColorTransfer,
+				ColorPrimaries: s.ColorPrimaries,
+				ColorSpace:     s.ColorSpace,
+			}
+			v.BitDepth, _ = strconv.Atoi(s.BitsPerRawSample)
+			if v.BitDepth == 0 {
+				v.BitDepth = pixelFormatDepth(s.PixFmt)
+			}
+			var ok bool
+			if v.FrameRate, ok = parseRational(s.AvgFrameRate); !ok {
+				v.FrameRate, _ = parseRational(s.RFrameRate)
+			}
+			v.Bitrate, _ = strconv.Atoi(s.BitRate)
+			v.Rotation = streamRotation(s.Tags["rotate"], s.SideDataList)
+			result.Video = v
+
+		case "audio":
+			if result.Audio != nil {
+				continue
+			}
+			a := &AudioStream{
+				Codec:         s.CodecName,
+				Profile:       s.Profile,
+				Channels:      s.Channels,
+				ChannelLayout: s.ChannelLayout,
+				BitsPerSample: s.BitsPerSample,
+			}
+			if a.BitsPerSample == 0 {
+				a.BitsPerSample, _ = strconv.Atoi(s.BitsPerRawSample)
+			}
+			a.SampleRate, _ = strconv.Atoi(s.SampleRate)
+			a.Bitrate, _ = strconv.Atoi(s.BitRate)
+			result.Audio = a
+
+		case "subtitle":
+			result.Subtitles = append(result.Subtitles, SubtitleStream{
+				Codec:    s.CodecName,
+				Language: s.Tags["language"],
+				Title:    s.Tags["title"],
+				Default:  s.Disposition["default"] == 1,
+				Forced:   s.Disposition["forced"] == 1,
+			})
+		}
+	}
+	return result, nil
+}
+
+// streamRotation is how far a stream must be turned clockwise, from the
+// display matrix side data of recent ffprobe versions, which holds the
+// counter-clockwise angle, or the rotate tag of older ones.
+func streamRotation(tag string, sideData []probeSideData) int {
+	for _, sd := range sideData {
+		if sd.SideDataType == "Display Matrix" {
+			return normaliseDegrees(-int(math.Round(sd.Rotation)))
+		}
+	}
+	if degrees, err := strconv.Atoi(tag); err == nil {
+		return normaliseDegrees(degrees)
+	}
+	return 0
+}
+
+func normaliseDegrees(degrees int) int {
+	return ((degrees % 360) + 360) % 360
+}
+
+// pixelFormatDepth guesses the bit depth from names like "yuv420p10le" when
+// the stream doesn't state it.
+func pixelFormatDepth(pixFmt string) int {
+	switch {
+	case pixFmt == "":
+		return 0
+	case strings.Contains(pixFmt, "p10"):
+		return 10
+	case strings.Contains(pixFmt, "p12"):
+		return 12
+	case strings.Contains(pixFmt, "p16"):
+		return 16
+	}
+	return 8
+}
+
+// Merge copies what ffprobe found into info. ffprobe reads the streams
+// themselves, so its values win; exiftool's stay where ffprobe has none.
+func (p *ProbeResult) Merge(info *exiftool.VideoInfo) {
+	if p.Duration > 0 {
+		info.Duration = p.Duration
+	}
+	if p.Bitrate > 0 {
+		info.Bitrate = p.Bitrate
+	}
+
+	if v := p.Video; v != nil {
+		if v.Width > 0 && v.Height > 0 {
+			info.Width, info.Height = v.Width, v.Height
+		}
+		if v.FrameRate.Num > 0 {
+			info.VideoFrameRate = v.FrameRate.Float64()
+			info.FrameRate = v.FrameRate.String()
+		}
+		info.Rotation = v.Rotation
+		info.Codec = v.Codec
+		info.Profile = v.Profile
+		info.PixelFormat = v.PixelFormat
+		info.BitDepth = v.BitDepth
+		info.HDR = v.HDR()
+		info.ColorTransfer = v.ColorTransfer
+		info.ColorPrimaries = v.ColorPrimaries
+		info.ColorSpace = v.ColorSpace
+	}
+
+	if a := p.Audio; a != nil {
+		info.AudioFormat = a.Codec
+		info.AudioChannels = a.Channels
+		info.AudioChannelLayout = a.ChannelLayout
+		if a.SampleRate > 0 {
+			info.AudioSampleRate = a.SampleRate
+		}
+		if a.BitsPerSample > 0 {
+			info.AudioBitsPerSample = a.BitsPerSample
+		}
+	}
+
+	info.Subtitles = nil
+	for _, s := range p.Subtitles {
+		info.Subtitles = append(info.Subtitles, exiftool.SubtitleTrack{
+			Codec:    s.Codec,
+			Language: s.Language,
+			Title:    s.Title,
+			Default:  s.Default,
+			Forced:   s.Forced,
+		})
+	}
+}
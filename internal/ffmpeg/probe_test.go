@@ -0,0 +1,91 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/mahdi-cpp/upload-service/internal/exiftool"
+)
+
+const iphoneProbe = `{
+	"streams": [
+		{"codec_type": "video", "codec_name": "hevc", "profile": "Main 10", "width": 3840, "height": 2160,
+		 "pix_fmt": "yuv420p10le", "color_space": "bt2020nc", "color_transfer": "arib-std-b67", "color_primaries": "bt2020",
+		 "r_frame_rate": "30/1", "avg_frame_rate": "30000/1001", "bit_rate": "40000000",
+		 "disposition": {"default": 1, "attached_pic": 0},
+		 "side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}]},
+		{"codec_type": "audio", "codec_name": "aac", "profile": "LC", "sample_rate": "48000", "channels": 2,
+		 "channel_layout": "stereo", "bit_rate": "192000"},
+		{"codec_type": "subtitle", "codec_name": "mov_text", "tags": {"language": "eng", "title": "English"},
+		 "disposition": {"default": 0, "forced": 1}},
+		{"codec_type": "video", "codec_name": "mjpeg", "width": 320, "height": 320, "disposition": {"attached_pic": 1}}
+	],
+	"format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "12.345000", "bit_rate": "40500000"}
+}`
+
+func TestParseProbe(t *testing.T) {
+
+	p, err := parseProbe([]byte(iphoneProbe))
+	if err != nil {
+		t.Fatalf("parseProbe: %v", err)
+	}
+
+	if p.Duration != 12.345 || p.Bitrate != 40500000 {
+		t.Errorf("unexpected format: duration %v, bitrate %d", p.Duration, p.Bitrate)
+	}
+	v := p.Video
+	if v == nil || v.Codec != "hevc" || v.BitDepth != 10 || v.Rotation != 90 || v.FrameRate != (Rational{30000, 1001}) {
+		t.Errorf("unexpected video stream %+v", v)
+	}
+	if a := p.Audio; a == nil || a.SampleRate != 48000 || a.ChannelLayout != "stereo" {
+		t.Errorf("unexpected audio stream %+v", a)
+	}
+	if len(p.Subtitles) != 1 || p.Subtitles[0].Language != "eng" || !p.Subtitles[0].Forced {
+		t.Errorf("unexpected subtitles %+v", p.Subtitles)
+	}
+}
+
+func TestProbeMerge(t *testing.T) {
+
+	p, err := parseProbe([]byte(iphoneProbe))
+	if err != nil {
+		t.Fatalf("parseProbe: %v", err)
+	}
+
+	info := exiftool.VideoInfo{Duration: 12, Encoder: "Lavf58.29.100", AudioBitsPerSample: 16}
+	p.Merge(&info)
+
+	if info.Duration != 12.345 || info.Width != 3840 || info.FrameRate != "30000/1001" || info.ColorTransfer != "arib-std-b67" || info.HDR != "hlg" {
+		t.Errorf("ffprobe values should win: %+v", info)
+	}
+	if info.Encoder != "Lavf58.29.100" || info.AudioBitsPerSample != 16 {
+		t.Errorf("exiftool values should stay where ffprobe has none: %+v", info)
+	}
+	if len(info.Subtitles) != 1 {
+		t.Errorf("subtitles = %+v", info.Subtitles)
+	}
+}
+
+func TestParseRational(t *testing.T) {
+	for value, want := range map[string]Rational{"30000/1001": {30000, 1001}, "25": {25, 1}, "0/0": {}, "": {}} {
+		if got, _ := parseRational(value); got != want {
+			t.Errorf("parseRational(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestStreamRotation(t *testing.T) {
+	if got := streamRotation("", []probeSideData{{SideDataType: "Display Matrix", Rotation: 90}}); got != 270 {
+		t.Errorf("display matrix 90 = %d, want 270", got)
+	}
+	if got := streamRotation("180", nil); got != 180 {
+		t.Errorf("rotate tag 180 = %d", got)
+	}
+}
+
+func TestVideoStreamHDR(t *testing.T) {
+	for transfer, want := range map[string]string{"smpte2084": "pq", "arib-std-b67": "hlg", "bt709": "", "": ""} {
+		if got := (&VideoStream{ColorTransfer: transfer}).HDR(); got != want {
+			t.Errorf("HDR(%q) = %q, want %q", transfer, got, want)
+		}
+	}
+}
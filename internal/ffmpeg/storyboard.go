@@ -0,0 +1,156 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+	"github.com/mahdi-cpp/upload-service/internal/metrics"
+)
+
+// StoryboardOptions controls how a video is sampled into sprite sheets.
+type StoryboardOptions struct {
+	Interval  time.Duration // between sampled frames
+	MaxTiles  int           // long videos are sampled less often to stay within this; 0 is unlimited
+	TileWidth int           // pixels; the height follows the video's aspect ratio
+	Columns   int           // tiles per sheet, across
+	Rows      int           // tiles per sheet, down
+	ToneMap   bool          // for HDR video
+}
+
+// Storyboard is a video sampled every Interval into tiles, laid out left to
+// right and top to bottom across one or more sprite sheets.
+type Storyboard struct {
+	Sheets     []string // JPEG paths, in order
+	Tiles      int
+	TileWidth  int
+	TileHeight int
+	Columns    int
+	Rows       int
+	Interval   time.Duration
+	Duration   time.Duration
+}
+
+// MakeStoryboard samples the video at inputPath into sprite sheets named
+// <outPrefix>_<n>.jpg. width and height are the video's display size and
+// duration its length, as ffprobe reports them. The storyboard covers the
+// sheets ffmpeg actually wrote, which is fewer when the video stream ends
+// before the container does.
+//
+// The command used is:
+// ffmpeg -i <inputPath> -vf "fps=1/<interval>,scale=<w>:<h>,tile=<cols>x<rows>" -q:v 5 -start_number 0 <outPrefix>_%d.jpg
+func MakeStoryboard(ctx context.Context, inputPath, outPrefix string, width, height int, duration time.Duration, opts StoryboardOptions) (*Storyboard, error) {
+	if width <= 0 || height <= 0 || duration <= 0 {
+		return nil, fmt.Errorf("storyboard: unknown video size or duration")
+	}
+	if opts.Interval <= 0 {
+		return nil, fmt.Errorf("storyboard: interval must be positive")
+	}
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg not found in PATH: %w", err)
+	}
+
+	sb := layoutStoryboard(width, height, duration, opts)
+
+	filter := fmt.Sprintf("fps=1/%s,scale=%d:%d,tile=%dx%d",
+		strconv.FormatFloat(sb.Interval.Seconds(), 'f', -1, 64), sb.TileWidth, sb.TileHeight, sb.Columns, sb.Rows)
+	args := []string{
+		"-hide_banner",
+		"-loglevel", "error",
+		"-i", inputPath,
+		"-an",
+		"-vf", videoFilter(opts.ToneMap, filter),
+		"-q:v", "5",
+		"-start_number", "0",
+		"-y", outPrefix + "_%d.jpg",
+	}
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	metrics.Spawned("ffmpeg")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	logging.FromContext(ctx).Debug("running ffmpeg", "args", args)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg storyboard: %w: %s", err, tail(stderr.String()))
+	}
+
+	sb.Sheets = writtenSheets(outPrefix)
+	if len(sb.Sheets) == 0 {
+		return nil, fmt.Errorf("ffmpeg storyboard: no sheets written")
+	}
+	sb.Tiles = min(sb.Tiles, len(sb.Sheets)*sb.Columns*sb.Rows)
+	logging.FromContext(ctx).Debug("created storyboard", "tiles", sb.Tiles, "sheets", len(sb.Sheets))
+	return sb, nil
+}
+
+// writtenSheets lists <outPrefix>_0.jpg, <outPrefix>_1.jpg and so on up to
+// the first one that doesn't exist.
+func writtenSheets(outPrefix string) []string {
+	var sheets []string
+	for n := 0; ; n++ {
+		sheet := outPrefix + "_" + strconv.Itoa(n) + ".jpg"
+		if _, err := os.Stat(sheet); err != nil {
+			return sheets
+		}
+		sheets = append(sheets, sheet)
+	}
+}
+
+// layoutStoryboard works out the tile size, the sampling interval and how
+// many tiles a video of duration gets.
+func layoutStoryboard(width, height int, duration time.Duration, opts StoryboardOptions) *Storyboard {
+	sb := &Storyboard{
+		TileWidth: opts.TileWidth,
+		Columns:   max(opts.Columns, 1),
+		Rows:      max(opts.Rows, 1),
+		Interval:  opts.Interval,
+		Duration:  duration,
+	}
+	// Even heights keep the JPEG encoder's chroma subsampling exact.
+	sb.TileHeight = max(int(math.Round(float64(sb.TileWidth)*float64(height)/float64(width)/2))*2, 2)
+
+	if opts.MaxTiles > 0 && duration > sb.Interval*time.Duration(opts.MaxTiles) {
+		sb.Interval = (duration + time.Duration(opts.MaxTiles) - 1) / time.Duration(opts.MaxTiles)
+		sb.Interval = sb.Interval.Round(time.Millisecond)
+	}
+	sb.Tiles = max(int((duration+sb.Interval-1)/sb.Interval), 1)
+	return sb
+}
+
+// WriteVTT writes a WebVTT thumbnails track with a cue per tile, naming its
+// sheet relative to the track, e.g. "<id>_storyboard_0.jpg#xywh=160,0,160,90".
+func (sb *Storyboard) WriteVTT(w io.Writer) error {
+	if _, err := io.WriteString(w, "WEBVTT\n"); err != nil {
+		return err
+	}
+	perSheet := sb.Columns * sb.Rows
+	for i := 0; i < sb.Tiles && i/perSheet < len(sb.Sheets); i++ {
+		start := sb.Interval * time.Duration(i)
+		end := min(start+sb.Interval, sb.Duration)
+		cell := i % perSheet
+		x, y := cell%sb.Columns*sb.TileWidth, cell/sb.Columns*sb.TileHeight
+
+		_, err := fmt.Fprintf(w, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			vttTimestamp(start), vttTimestamp(end), filepath.Base(sb.Sheets[i/perSheet]), x, y, sb.TileWidth, sb.TileHeight)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vttTimestamp formats d as WebVTT's hh:mm:ss.ttt.
+func vttTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,89 @@
+package ffmpeg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLayoutStoryboard(t *testing.T) {
+
+	opts := StoryboardOptions{Interval: 2 * time.Second, MaxTiles: 100, TileWidth: 160, Columns: 10, Rows: 10}
+
+	sb := layoutStoryboard(1920, 1080, 9*time.Second, opts)
+	if sb.TileHeight != 90 || sb.Tiles != 5 || sb.Interval != 2*time.Second {
+		t.Errorf("unexpected layout %+v", sb)
+	}
+
+	// An hour at 2 s would be 1800 tiles; the interval stretches instead.
+	sb = layoutStoryboard(1080, 1920, time.Hour, opts)
+	if sb.Tiles != 100 || sb.Interval != 36*time.Second || sb.TileHeight != 284 {
+		t.Errorf("unexpected long layout %+v", sb)
+	}
+}
+
+func TestMakeStoryboardRejectsInterval(t *testing.T) {
+
+	_, err := MakeStoryboard(context.Background(), "in.mp4", "out", 1920, 1080, time.Minute, StoryboardOptions{TileWidth: 160})
+	if err == nil || !strings.Contains(err.Error(), "interval") {
+		t.Errorf("zero interval: got %v", err)
+	}
+}
+
+func TestWrittenSheets(t *testing.T) {
+
+	prefix := filepath.Join(t.TempDir(), "id_storyboard")
+	for _, n := range []string{"0", "1", "3"} {
+		if err := os.WriteFile(prefix+"_"+n+".jpg", []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Sheet 3 is past the gap, so it isn't part of the storyboard.
+	sheets := writtenSheets(prefix)
+	if len(sheets) != 2 || sheets[1] != prefix+"_1.jpg" {
+		t.Errorf("unexpected sheets %v", sheets)
+	}
+	if sheets := writtenSheets(prefix + "_missing"); len(sheets) != 0 {
+		t.Errorf("unexpected sheets %v", sheets)
+	}
+}
+
+func TestStoryboardWriteVTT(t *testing.T) {
+
+	sb := &Storyboard{
+		Sheets:     []string{"/tmp/id_storyboard_0.jpg", "/tmp/id_storyboard_1.jpg"},
+		Tiles:      5,
+		TileWidth:  160,
+		TileHeight: 90,
+		Columns:    2,
+		Rows:       2,
+		Interval:   2 * time.Second,
+		Duration:   9 * time.Second,
+	}
+
+	var track strings.Builder
+	if err := sb.WriteVTT(&track); err != nil {
+		t.Fatalf("WriteVTT: %v", err)
+	}
+
+	for _, want := range []string{
+		"WEBVTT\n",
+		"00:00:00.000 --> 00:00:02.000\nid_storyboard_0.jpg#xywh=0,0,160,90\n",
+		"00:00:06.000 --> 00:00:08.000\nid_storyboard_0.jpg#xywh=160,90,160,90\n",
+		"00:00:08.000 --> 00:00:09.000\nid_storyboard_1.jpg#xywh=0,0,160,90\n",
+	} {
+		if !strings.Contains(track.String(), want) {
+			t.Errorf("track is missing %q:\n%s", want, track.String())
+		}
+	}
+}
+
+func TestVTTTimestamp(t *testing.T) {
+	if got := vttTimestamp(time.Hour + 2*time.Minute + 3*time.Second + 45*time.Millisecond); got != "01:02:03.045" {
+		t.Errorf("vttTimestamp = %q", got)
+	}
+}
@@ -0,0 +1,86 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/mahdi-cpp/upload-service/internal/jobs"
+	"github.com/mahdi-cpp/upload-service/internal/metrics"
+)
+
+// Binary checks that an external tool is on PATH and reports the first line
+// it prints for versionArgs, e.g. "ffmpeg version 6.1.1".
+func Binary(name string, versionArgs ...string) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			return nil, err
+		}
+
+		cmd := exec.CommandContext(ctx, path, versionArgs...)
+		metrics.Spawned(name)
+		output, err := cmd.Output()
+		if err != nil {
+			return map[string]any{"path": path}, fmt.Errorf("%s %v: %w", name, versionArgs, err)
+		}
+
+		line, _, _ := bytes.Cut(output, []byte("\n"))
+		return map[string]any{
+			"path":    path,
+			"version": string(bytes.TrimSpace(line)),
+		}, nil
+	}
+}
+
+// Directory checks that dir exists, accepts new files and has at least
+// minFree bytes available.
+func Directory(dir string, minFree uint64) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(dir, &stat); err != nil {
+			return nil, err
+		}
+		free := stat.Bavail * uint64(stat.Bsize)
+		details := map[string]any{
+			"path":      dir,
+			"freeBytes": free,
+			"minFree":   minFree,
+		}
+
+		probe, err := os.CreateTemp(dir, ".health-*")
+		if err != nil {
+			return details, fmt.Errorf("not writable: %w", err)
+		}
+		probe.Close()
+		os.Remove(probe.Name())
+
+		if free < minFree {
+			return details, fmt.Errorf("only %d bytes free, need %d", free, minFree)
+		}
+		return details, nil
+	}
+}
+
+// JobPool fails when the processing queue is full, meaning new uploads
+// would be turned away.
+func JobPool(pool *jobs.Pool) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		stats := pool.Stats()
+		details := map[string]any{
+			"capacity":  stats.Capacity,
+			"running":   stats.Running,
+			"waiting":   stats.Waiting,
+			"queueSize": stats.QueueSize,
+			"rejected":  stats.Rejected,
+		}
+		if stats.Running >= stats.Capacity && stats.Waiting >= stats.QueueSize {
+			return details, errors.New("job queue is full")
+		}
+		return details, nil
+	}
+}
@@ -0,0 +1,131 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Status is the outcome of a single check or of a whole report.
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusDegraded Status = "degraded" // an optional dependency is unavailable
+	StatusFail     Status = "fail"
+)
+
+// Result is what one check reports about a dependency.
+type Result struct {
+	Name     string         `json:"name"`
+	Status   Status         `json:"status"`
+	Critical bool           `json:"critical"`
+	Error    string         `json:"error,omitempty"`
+	Details  map[string]any `json:"details,omitempty"`
+	Duration string         `json:"duration"`
+}
+
+// Report aggregates every check. Status is fail when a critical check failed
+// and degraded when only optional checks did.
+type Report struct {
+	Status    Status    `json:"status"`
+	CheckedAt time.Time `json:"checkedAt"`
+	Checks    []Result  `json:"checks"`
+}
+
+// CheckFunc inspects a dependency and returns details worth reporting.
+type CheckFunc func(ctx context.Context) (map[string]any, error)
+
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+// Checker runs registered checks concurrently and caches the report for a
+// short while, so frequent probes don't keep spawning external processes.
+type Checker struct {
+	timeout  time.Duration
+	cacheTTL time.Duration
+	checks   []check
+
+	mu     sync.Mutex
+	last   *Report
+	expiry time.Time
+}
+
+// NewChecker gives each check at most timeout and reuses a report for cacheTTL.
+func NewChecker(timeout, cacheTTL time.Duration) *Checker {
+	return &Checker{timeout: timeout, cacheTTL: cacheTTL}
+}
+
+// Register adds a check. A failed critical check makes the service not ready.
+func (c *Checker) Register(name string, critical bool, fn CheckFunc) {
+	c.checks = append(c.checks, check{name: name, critical: critical, fn: fn})
+}
+
+// Run returns the cached report or runs every check. The checks don't stop
+// when ctx is cancelled: the report is shared by later callers, so one client
+// going away must not cache a failure for all of them.
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.last != nil && now.Before(c.expiry) {
+		return *c.last
+	}
+
+	report := Report{
+		Status:    StatusOK,
+		CheckedAt: now,
+		Checks:    make([]Result, len(c.checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, chk := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = c.runCheck(ctx, chk)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		switch {
+		case result.Status == StatusFail && result.Critical:
+			report.Status = StatusFail
+		case result.Status != StatusOK && report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+
+	c.last = &report
+	c.expiry = now.Add(c.cacheTTL)
+	return report
+}
+
+func (c *Checker) runCheck(ctx context.Context, chk check) Result {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := chk.fn(ctx)
+
+	result := Result{
+		Name:     chk.name,
+		Status:   StatusOK,
+		Critical: chk.critical,
+		Details:  details,
+		Duration: time.Since(start).Round(time.Microsecond).String(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.Status = StatusFail
+		if !chk.critical {
+			result.Status = StatusDegraded
+		}
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mahdi-cpp/upload-service/internal/jobs"
+)
+
+func TestCheckerAggregatesStatus(t *testing.T) {
+
+	ok := func(ctx context.Context) (map[string]any, error) { return nil, nil }
+	fail := func(ctx context.Context) (map[string]any, error) { return nil, errors.New("down") }
+
+	checker := NewChecker(time.Second, 0)
+	checker.Register("a", true, ok)
+	checker.Register("b", false, fail)
+	if report := checker.Run(context.Background()); report.Status != StatusDegraded {
+		t.Errorf("optional failure: status = %s, want %s", report.Status, StatusDegraded)
+	}
+
+	checker.Register("c", true, fail)
+	report := checker.Run(context.Background())
+	if report.Status != StatusFail {
+		t.Errorf("critical failure: status = %s, want %s", report.Status, StatusFail)
+	}
+	if len(report.Checks) != 3 || report.Checks[2].Name != "c" || report.Checks[2].Error != "down" {
+		t.Errorf("unexpected checks %+v", report.Checks)
+	}
+}
+
+func TestCheckerCachesReport(t *testing.T) {
+
+	runs := 0
+	checker := NewChecker(time.Second, time.Minute)
+	checker.Register("count", true, func(ctx context.Context) (map[string]any, error) {
+		runs++
+		return nil, nil
+	})
+
+	checker.Run(context.Background())
+	checker.Run(context.Background())
+	if runs != 1 {
+		t.Errorf("check ran %d times, want 1", runs)
+	}
+}
+
+func TestCheckerIgnoresCallerCancellation(t *testing.T) {
+
+	checker := NewChecker(time.Second, time.Minute)
+	checker.Register("ctx", true, func(ctx context.Context) (map[string]any, error) {
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if report := checker.Run(ctx); report.Status != StatusOK {
+		t.Errorf("cancelled caller: status = %s, want %s", report.Status, StatusOK)
+	}
+}
+
+func TestDirectory(t *testing.T) {
+
+	dir := t.TempDir()
+	if _, err := Directory(dir, 0)(context.Background()); err != nil {
+		t.Errorf("writable temp dir: %v", err)
+	}
+	if _, err := Directory(dir, 1<<62)(context.Background()); err == nil {
+		t.Errorf("expected a free space error")
+	}
+	if _, err := Directory(dir+"/missing", 0)(context.Background()); err == nil {
+		t.Errorf("expected an error for a missing directory")
+	}
+}
+
+func TestBinaryMissing(t *testing.T) {
+
+	if _, err := Binary("definitely-not-installed-binary", "-version")(context.Background()); err == nil {
+		t.Errorf("expected an error for a missing binary")
+	}
+}
+
+func TestJobPool(t *testing.T) {
+
+	pool := jobs.NewPool(1, 0, time.Millisecond)
+	check := JobPool(pool)
+	if _, err := check(context.Background()); err != nil {
+		t.Errorf("idle pool: %v", err)
+	}
+
+	release, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if _, err := check(context.Background()); err == nil {
+		t.Errorf("expected a full pool to fail")
+	}
+}
@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// Vips starts libvips and checks that it provides every named operation,
+// e.g. "heifsave" for AVIF output.
+func Vips(operations ...string) CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		details := map[string]any{
+			"version":    fmt.Sprintf("%d.%d.%d", vips.MajorVersion, vips.MinorVersion, vips.MicroVersion),
+			"operations": operations,
+		}
+
+		var missing []string
+		for _, operation := range operations {
+			if !vips.HasOperation(operation) {
+				missing = append(missing, operation)
+			}
+		}
+		if len(missing) > 0 {
+			return details, fmt.Errorf("missing operations: %s", strings.Join(missing, ", "))
+		}
+		return details, nil
+	}
+}
+
+// PDF checks that document covers can be rendered, by libvips' pdfload or
+// else poppler's pdftoppm, which the thumbnailer falls back to.
+func PDF() CheckFunc {
+	return func(ctx context.Context) (map[string]any, error) {
+		if vips.HasOperation("pdfload") {
+			return map[string]any{"renderer": "pdfload"}, nil
+		}
+		path, err := exec.LookPath("pdftoppm")
+		if err != nil {
+			return nil, fmt.Errorf("libvips has no pdfload and %w", err)
+		}
+		return map[string]any{"renderer": "pdftoppm", "path": path}, nil
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"strconv"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -16,29 +15,23 @@ import (
 	"github.com/google/uuid"
 )
 
+// UserIDKey is the gin context key under which the auth middleware stores the verified user ID.
+const UserIDKey = "userID"
+
 // GetUserID از Gin context، user_id را به صورت string دریافت می‌کند.
+// این تابع باید بعد از middleware احراز هویت استفاده شود
 func GetUserID(c *gin.Context) (string, bool) {
+	userID, exists := c.Get(UserIDKey)
+	if !exists {
+		return "", false
+	}
 
-	//// این تابع باید بعد از middleware احراز هویت استفاده شود
-	//userID, exists := c.Read("X-User-ID")
-	//if !exists {
-	//	return "", false
-	//}
-	//
-	//userIDStr, ok := userID.(string)
-	//if !ok {
-	//	return "", false
-	//}
-	//
-	//return userIDStr, true
-
-	//// این تابع باید بعد از middleware احراز هویت استفاده شود
-	userID := c.GetHeader("X-User-ID")
-	if userID == "" {
+	userIDStr, ok := userID.(string)
+	if !ok || userIDStr == "" {
 		return "", false
 	}
 
-	return userID, true
+	return userIDStr, true
 }
 
 // MakeRequest Helper function to make HTTP requests
@@ -68,9 +61,6 @@ func MakeRequest(t *testing.T, method, endpoint string, queryParams map[string]i
 		bodyReader = bytes.NewReader(jsonData)
 	}
 
-	fmt.Println(u.String())
-	fmt.Println("")
-
 	// create request
 	req, err := http.NewRequest(method, u.String(), bodyReader)
 	if err != nil {
@@ -120,14 +110,6 @@ func GetFileSize(filepath string) (int64, error) {
 	return fileInfo.Size(), nil
 }
 
-func GetUserId(c *gin.Context) (int, error) {
-
-	userIDStr := c.GetHeader("userID")
-	fmt.Println(userIDStr)
-
-	return strconv.Atoi(userIDStr)
-}
-
 func GenerateUUID() (uuid.UUID, error) {
 	u7, err2 := uuid.NewV7()
 	if err2 != nil {
@@ -0,0 +1,259 @@
+package imagecache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mahdi-cpp/upload-service/internal/media"
+)
+
+// Cache is an LRU of image files read from below a base directory. Unlike
+// image_loader.ImageLoader, entries can be invalidated when files change.
+type Cache struct {
+	basePath   string
+	maxEntries int
+	maxBytes   int64
+
+	mu      sync.Mutex
+	ll      *list.List // front is most recently used
+	entries map[string]*list.Element
+	bytes   int64
+	stats   Stats
+
+	// generation changes on every invalidation, so a load that raced with
+	// one doesn't put the stale file back in the cache.
+	generation uint64
+}
+
+type entry struct {
+	key  string
+	data []byte
+}
+
+// Stats counts cache activity since startup.
+type Stats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	LoadErrors    uint64 `json:"loadErrors"`
+	Evictions     uint64 `json:"evictions"`
+	Invalidations uint64 `json:"invalidations"`
+	Entries       int    `json:"entries"`
+	Bytes         int64  `json:"bytes"`
+	MaxEntries    int    `json:"maxEntries"`
+	MaxBytes      int64  `json:"maxBytes"`
+}
+
+// New caches images read from below basePath, evicting the least recently
+// used once it holds more than maxEntries images or maxBytes bytes.
+// A limit of 0 disables that bound.
+func New(basePath string, maxEntries int, maxBytes int64) *Cache {
+	return &Cache{
+		basePath:   basePath,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// GetLocalBasePath returns the directory image IDs are resolved against.
+func (c *Cache) GetLocalBasePath() string {
+	return c.basePath
+}
+
+// LoadImage returns the image stored at imageID below the base path,
+// reading it from disk on a miss.
+func (c *Cache) LoadImage(ctx context.Context, imageID string) ([]byte, error) {
+	key := normalize(imageID)
+
+	c.mu.Lock()
+	if element, ok := c.entries[key]; ok {
+		c.ll.MoveToFront(element)
+		c.stats.Hits++
+		data := element.Value.(*entry).data
+		c.mu.Unlock()
+		return data, nil
+	}
+	c.stats.Misses++
+	generation := c.generation
+	c.mu.Unlock()
+
+	data, err := c.read(key)
+	if err != nil {
+		c.mu.Lock()
+		c.stats.LoadErrors++
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if generation == c.generation {
+		c.add(key, data)
+	}
+	c.mu.Unlock()
+	return data, nil
+}
+
+// Preload reads imageID into the cache without counting a hit or miss.
+// It is used to warm the cache at startup.
+func (c *Cache) Preload(imageID string) error {
+	key := normalize(imageID)
+
+	c.mu.Lock()
+	_, cached := c.entries[key]
+	generation := c.generation
+	c.mu.Unlock()
+	if cached {
+		return nil
+	}
+
+	data, err := c.read(key)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if generation == c.generation {
+		c.add(key, data)
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// PreloadPath preloads a file given by its absolute path.
+func (c *Cache) PreloadPath(path string) error {
+	key, ok := c.keyFor(path)
+	if !ok {
+		return fmt.Errorf("%s is outside %s", path, c.basePath)
+	}
+	return c.Preload(key)
+}
+
+func (c *Cache) read(key string) ([]byte, error) {
+	path := filepath.Join(c.basePath, key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if !media.Detect(data).Format.IsImage() {
+		return nil, fmt.Errorf("invalid image format for %s", key)
+	}
+	return data, nil
+}
+
+// add stores data under key; c.mu must be held.
+func (c *Cache) add(key string, data []byte) {
+	// An image larger than the whole cache would only evict everything else.
+	if c.maxBytes > 0 && int64(len(data)) > c.maxBytes {
+		return
+	}
+
+	if element, ok := c.entries[key]; ok {
+		c.bytes -= int64(len(element.Value.(*entry).data))
+		element.Value.(*entry).data = data
+		c.bytes += int64(len(data))
+		c.ll.MoveToFront(element)
+	} else {
+		c.entries[key] = c.ll.PushFront(&entry{key: key, data: data})
+		c.bytes += int64(len(data))
+	}
+
+	for (c.maxEntries > 0 && c.ll.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes) {
+		c.remove(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove drops element; c.mu must be held.
+func (c *Cache) remove(element *list.Element) {
+	e := c.ll.Remove(element).(*entry)
+	delete(c.entries, e.key)
+	c.bytes -= int64(len(e.data))
+}
+
+// Invalidate drops the entry for imageID, reporting whether one was cached.
+func (c *Cache) Invalidate(imageID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	element, ok := c.entries[normalize(imageID)]
+	if ok {
+		c.remove(element)
+		c.stats.Invalidations++
+	}
+	return ok
+}
+
+// InvalidatePath drops the entry for a file given by its absolute path.
+// Paths outside the base path are ignored.
+func (c *Cache) InvalidatePath(path string) bool {
+	key, ok := c.keyFor(path)
+	return ok && c.Invalidate(key)
+}
+
+// InvalidatePrefix drops every entry at or below the image ID prefix, for
+// example a whole album directory, and returns how many were dropped.
+func (c *Cache) InvalidatePrefix(prefix string) int {
+	prefix = normalize(prefix)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	dropped := 0
+	for key, element := range c.entries {
+		if prefix == "/" || key == prefix || strings.HasPrefix(key, prefix+"/") {
+			c.remove(element)
+			dropped++
+		}
+	}
+	c.stats.Invalidations += uint64(dropped)
+	return dropped
+}
+
+// InvalidatePathPrefix is InvalidatePrefix for an absolute directory path.
+func (c *Cache) InvalidatePathPrefix(path string) int {
+	key, ok := c.keyFor(path)
+	if !ok {
+		return 0
+	}
+	return c.InvalidatePrefix(key)
+}
+
+// Clear drops every entry and returns how many there were.
+func (c *Cache) Clear() int {
+	return c.InvalidatePrefix("/")
+}
+
+// keyFor maps an absolute file path to the image ID serving it.
+func (c *Cache) keyFor(path string) (string, bool) {
+	rel, err := filepath.Rel(filepath.Join("/", c.basePath), filepath.Join("/", path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return normalize(rel), true
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.ll.Len()
+	stats.Bytes = c.bytes
+	stats.MaxEntries = c.maxEntries
+	stats.MaxBytes = c.maxBytes
+	return stats
+}
+
+// normalize makes "a/b.jpg" and "/a/b.jpg" the same key.
+func normalize(imageID string) string {
+	return filepath.Join("/", imageID)
+}
@@ -0,0 +1,137 @@
+package imagecache
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writePNG(t *testing.T, path string) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadImageCachesAndEvicts(t *testing.T) {
+
+	base := t.TempDir()
+	for _, name := range []string{"a.png", "b.png", "c.png"} {
+		writePNG(t, filepath.Join(base, "ns", name))
+	}
+
+	cache := New(base, 2, 0)
+	ctx := context.Background()
+	for _, id := range []string{"/ns/a.png", "ns/a.png", "/ns/b.png", "/ns/c.png"} {
+		if _, err := cache.LoadImage(ctx, id); err != nil {
+			t.Fatalf("LoadImage(%s): %v", id, err)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 3 || stats.Evictions != 1 || stats.Entries != 2 || stats.Bytes == 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	if _, err := cache.LoadImage(ctx, "/ns/missing.png"); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+	if cache.Stats().LoadErrors != 1 {
+		t.Errorf("expected a load error to be counted")
+	}
+}
+
+func TestInvalidatePath(t *testing.T) {
+
+	base := t.TempDir()
+	path := filepath.Join(base, "ns", "a.png")
+	writePNG(t, path)
+
+	cache := New(base, 10, 0)
+	if _, err := cache.LoadImage(context.Background(), "/ns/a.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	if cache.InvalidatePath("/elsewhere/ns/a.png") {
+		t.Errorf("a path outside the base must not match")
+	}
+	if !cache.InvalidatePath(path) {
+		t.Errorf("expected the cached entry to be invalidated")
+	}
+	if stats := cache.Stats(); stats.Entries != 0 || stats.Bytes != 0 || stats.Invalidations != 1 {
+		t.Errorf("unexpected stats after invalidation %+v", stats)
+	}
+}
+
+func TestByteLimit(t *testing.T) {
+
+	base := t.TempDir()
+	for _, name := range []string{"a.png", "b.png", "c.png"} {
+		writePNG(t, filepath.Join(base, name))
+	}
+	info, err := os.Stat(filepath.Join(base, "a.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Room for two images, whatever the entry limit says.
+	cache := New(base, 100, 2*info.Size())
+	for _, id := range []string{"a.png", "b.png", "c.png"} {
+		if _, err := cache.LoadImage(context.Background(), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Evictions != 1 || stats.Bytes > stats.MaxBytes {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// An image larger than the whole cache is served but not kept.
+	small := New(base, 100, info.Size()-1)
+	if _, err := small.LoadImage(context.Background(), "a.png"); err != nil {
+		t.Fatal(err)
+	}
+	if small.Stats().Entries != 0 {
+		t.Errorf("oversized image should not be cached")
+	}
+}
+
+func TestInvalidatePrefixAndPreload(t *testing.T) {
+
+	base := t.TempDir()
+	for _, name := range []string{"album/a.png", "album/b.png", "albums/c.png", "other/d.png"} {
+		writePNG(t, filepath.Join(base, name))
+	}
+
+	cache := New(base, 10, 0)
+	for _, id := range []string{"album/a.png", "album/b.png", "albums/c.png"} {
+		if err := cache.Preload(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cache.PreloadPath(filepath.Join(base, "other/d.png")); err != nil {
+		t.Fatal(err)
+	}
+	if stats := cache.Stats(); stats.Entries != 4 || stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("preloading should fill the cache without counting requests: %+v", stats)
+	}
+
+	if dropped := cache.InvalidatePrefix("/album"); dropped != 2 {
+		t.Errorf("InvalidatePrefix dropped %d entries, want 2", dropped)
+	}
+	if dropped := cache.InvalidatePathPrefix(filepath.Join(base, "other")); dropped != 1 {
+		t.Errorf("InvalidatePathPrefix dropped %d entries, want 1", dropped)
+	}
+	if dropped := cache.Clear(); dropped != 1 {
+		t.Errorf("Clear dropped %d entries, want 1", dropped)
+	}
+}
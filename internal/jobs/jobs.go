@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrSaturated is returned when every slot is busy and the queue is full,
+// or a queued job waited longer than the pool allows.
+var ErrSaturated = errors.New("processing capacity is saturated")
+
+// Pool is a semaphore limiting how many heavy jobs (ffmpeg, libvips,
+// exiftool) run at once. Callers beyond the limit queue for a while
+// before being turned away.
+type Pool struct {
+	slots    chan struct{}
+	maxQueue int
+	maxWait  time.Duration
+
+	mu       sync.Mutex
+	waiting  int
+	rejected uint64
+	done     uint64
+}
+
+// NewPool allows size concurrent jobs and up to queue waiting jobs, each
+// waiting at most maxWait for a slot.
+func NewPool(size, queue int, maxWait time.Duration) *Pool {
+	return &Pool{
+		slots:    make(chan struct{}, size),
+		maxQueue: queue,
+		maxWait:  maxWait,
+	}
+}
+
+// Acquire waits for a free slot. The returned function releases it and must be called exactly once.
+func (p *Pool) Acquire(ctx context.Context) (func(), error) {
+
+	// Fast path: a slot is free.
+	select {
+	case p.slots <- struct{}{}:
+		return p.release, nil
+	default:
+	}
+
+	p.mu.Lock()
+	if p.waiting >= p.maxQueue {
+		p.rejected++
+		p.mu.Unlock()
+		return nil, ErrSaturated
+	}
+	p.waiting++
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.waiting--
+		p.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(p.maxWait)
+	defer timer.Stop()
+
+	select {
+	case p.slots <- struct{}{}:
+		return p.release, nil
+	case <-timer.C:
+		p.mu.Lock()
+		p.rejected++
+		p.mu.Unlock()
+		return nil, ErrSaturated
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (p *Pool) release() {
+	<-p.slots
+	p.mu.Lock()
+	p.done++
+	p.mu.Unlock()
+}
+
+type Stats struct {
+	Capacity  int    `json:"capacity"`
+	Running   int    `json:"running"`
+	Waiting   int    `json:"waiting"`
+	QueueSize int    `json:"queueSize"`
+	Rejected  uint64 `json:"rejected"`
+	Completed uint64 `json:"completed"`
+}
+
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return Stats{
+		Capacity:  cap(p.slots),
+		Running:   len(p.slots),
+		Waiting:   p.waiting,
+		QueueSize: p.maxQueue,
+		Rejected:  p.rejected,
+		Completed: p.done,
+	}
+}
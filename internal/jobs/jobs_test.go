@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolQueuesAndRejects(t *testing.T) {
+
+	pool := NewPool(1, 1, 50*time.Millisecond)
+	ctx := context.Background()
+
+	release, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("first Acquire: %v", err)
+	}
+
+	// The second caller queues and gets the slot once it is released.
+	acquired := make(chan error, 1)
+	go func() {
+		r, err := pool.Acquire(ctx)
+		if err == nil {
+			r()
+		}
+		acquired <- err
+	}()
+
+	waitFor(t, func() bool { return pool.Stats().Waiting == 1 })
+
+	// The queue is full, so a third caller is turned away at once.
+	if _, err := pool.Acquire(ctx); !errors.Is(err, ErrSaturated) {
+		t.Errorf("third Acquire: got %v, want ErrSaturated", err)
+	}
+
+	release()
+	if err := <-acquired; err != nil {
+		t.Errorf("queued Acquire: %v", err)
+	}
+
+	stats := pool.Stats()
+	if stats.Running != 0 || stats.Completed != 2 || stats.Rejected != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestPoolWaitTimeout(t *testing.T) {
+
+	pool := NewPool(1, 5, 10*time.Millisecond)
+	release, _ := pool.Acquire(context.Background())
+	defer release()
+
+	if _, err := pool.Acquire(context.Background()); !errors.Is(err, ErrSaturated) {
+		t.Errorf("got %v, want ErrSaturated after waiting", err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,66 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/mahdi-cpp/upload-service/internal/config"
+)
+
+type loggerKey struct{}
+
+// Setup installs the default slog logger using config.LogFormatEnv and
+// config.LogLevelEnv. The standard log package writes through it as well.
+func Setup() error {
+	logger, err := New(os.Stderr, os.Getenv(config.LogFormatEnv), os.Getenv(config.LogLevelEnv))
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// New builds a logger writing "json" or "text" (the default) at the given level.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("logging: invalid level %q", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("logging: invalid format %q", format)
+	}
+}
+
+// FromContext returns the request-scoped logger, or the default logger when
+// ctx carries none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+// NewContext returns a copy of ctx carrying logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// With returns a copy of ctx whose logger adds the given attributes to every line.
+func With(ctx context.Context, args ...any) context.Context {
+	return NewContext(ctx, FromContext(ctx).With(args...))
+}
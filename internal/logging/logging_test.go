@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+)
+
+func TestNew(t *testing.T) {
+
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "key", "value")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected a single JSON line, got %q", buf.String())
+	}
+	if line["msg"] != "shown" || line["key"] != "value" {
+		t.Errorf("unexpected log line %v", line)
+	}
+
+	if _, err := New(&buf, "xml", ""); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+	if _, err := New(&buf, "", "loud"); err == nil {
+		t.Errorf("expected an error for an unknown level")
+	}
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	logger, _ := New(&buf, "json", "debug")
+	slog.SetDefault(logger)
+	defer slog.SetDefault(previous)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/media", func(c *gin.Context) {
+		AddAttrs(c, "media_id", "m1")
+		FromContext(c.Request.Context()).Info("processing")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/media", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Errorf("response request ID = %q, want abc-123", got)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %d: %s", len(lines), buf.String())
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, `"request_id":"abc-123"`) || !strings.Contains(line, `"media_id":"m1"`) {
+			t.Errorf("log line is missing request or media ID: %s", line)
+		}
+	}
+
+	// An unusable incoming ID is replaced.
+	req = httptest.NewRequest(http.MethodGet, "/media", nil)
+	req.Header.Set(RequestIDHeader, "bad id\n")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if got := rec.Header().Get(RequestIDHeader); got == "" || got == "bad id\n" {
+		t.Errorf("expected a generated request ID, got %q", got)
+	}
+}
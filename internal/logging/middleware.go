@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/tracing"
+)
+
+// RequestIDHeader carries the correlation ID in requests and responses.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDKey is the gin context key holding the request ID.
+const RequestIDKey = "requestID"
+
+const maxRequestIDLength = 128
+
+// Middleware accepts an incoming X-Request-ID or generates one, echoes it in
+// the response and attaches a logger carrying it to the request context.
+// Each request is logged once it completes.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Set(RequestIDKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		logger := slog.Default().With("request_id", requestID)
+		if traceID := tracing.TraceID(c.Request.Context()); traceID != "" {
+			logger = logger.With("trace_id", traceID)
+		}
+		c.Request = c.Request.WithContext(NewContext(c.Request.Context(), logger))
+
+		c.Next()
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+		FromContext(c.Request.Context()).Log(c.Request.Context(), level, "request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"duration", time.Since(start),
+			"client_ip", c.ClientIP(),
+		)
+	}
+}
+
+// AddAttrs attaches attributes to the request logger for the rest of the request.
+func AddAttrs(c *gin.Context, args ...any) {
+	c.Request = c.Request.WithContext(With(c.Request.Context(), args...))
+}
+
+// validRequestID accepts short IDs of printable ASCII so a client can't inject
+// newlines or huge values into the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
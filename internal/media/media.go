@@ -0,0 +1,315 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Format is a container format recognised from a file's leading bytes.
+type Format string
+
+const (
+	Unknown Format = ""
+	JPEG    Format = "jpeg"
+	PNG     Format = "png"
+	GIF     Format = "gif"
+	WebP    Format = "webp"
+	HEIC    Format = "heic" // HEIF with HEVC-coded images, as written by phones
+	HEIF    Format = "heif" // HEIF with another or unstated codec
+	AVIF    Format = "avif" // HEIF with AV1-coded images
+	MP4     Format = "mp4"
+	MOV     Format = "mov"
+	TIFF    Format = "tiff"
+	PDF     Format = "pdf"
+	MP3     Format = "mp3"
+	M4A     Format = "m4a" // AAC or ALAC in an MP4 container
+	OGG     Format = "ogg" // Ogg Vorbis
+	Opus    Format = "opus"
+	WAV     Format = "wav"
+	FLAC    Format = "flac"
+
+	// Camera RAW formats. NEF and ARW are plain TIFF containers, told apart
+	// from other TIFFs by WithName.
+	DNG Format = "dng"
+	CR2 Format = "cr2"
+	CR3 Format = "cr3"
+	NEF Format = "nef"
+	ARW Format = "arw"
+)
+
+// sniffLen is how much of a file Detect needs to see.
+const sniffLen = 512
+
+// Info describes a detected file.
+type Info struct {
+	Format Format `json:"format"`
+	Brand  string `json:"brand,omitempty"` // ISO-BMFF major brand, e.g. "heic"
+
+	// Sequence is set for HEIF image sequences (burst or animation brands
+	// such as msf1 and hevc) as opposed to still-image collections.
+	Sequence bool `json:"sequence,omitempty"`
+}
+
+var formats = map[Format]struct {
+	ext, mime string
+	image     bool
+}{
+	JPEG: {".jpg", "image/jpeg", true},
+	PNG:  {".png", "image/png", true},
+	GIF:  {".gif", "image/gif", true},
+	WebP: {".webp", "image/webp", true},
+	HEIC: {".heic", "image/heic", true},
+	HEIF: {".heif", "image/heif", true},
+	AVIF: {".avif", "image/avif", true},
+	MP4:  {".mp4", "video/mp4", false},
+	MOV:  {".mov", "video/quicktime", false},
+	TIFF: {".tiff", "image/tiff", true},
+	PDF:  {".pdf", "application/pdf", false},
+	MP3:  {".mp3", "audio/mpeg", false},
+	M4A:  {".m4a", "audio/mp4", false},
+	OGG:  {".ogg", "audio/ogg", false},
+	Opus: {".opus", "audio/ogg", false},
+	WAV:  {".wav", "audio/wav", false},
+	FLAC: {".flac", "audio/flac", false},
+	DNG:  {".dng", "image/x-adobe-dng", true},
+	CR2:  {".cr2", "image/x-canon-cr2", true},
+	CR3:  {".cr3", "image/x-canon-cr3", true},
+	NEF:  {".nef", "image/x-nikon-nef", true},
+	ARW:  {".arw", "image/x-sony-arw", true},
+}
+
+// Ext is the file extension originals of this format are stored with.
+func (f Format) Ext() string {
+	return formats[f].ext
+}
+
+// MimeType is the Content-Type files of this format are served with.
+func (f Format) MimeType() string {
+	if mime := formats[f].mime; mime != "" {
+		return mime
+	}
+	return "application/octet-stream"
+}
+
+// IsImage reports whether f is a still or animated image format.
+func (f Format) IsImage() bool {
+	return formats[f].image
+}
+
+// IsHEIF reports whether f is stored in a HEIF container, which many
+// clients can't decode and so gets a display derivative.
+func (f Format) IsHEIF() bool {
+	return f == HEIC || f == HEIF || f == AVIF
+}
+
+// IsDocument reports whether f is a document format, such as PDF, whose
+// first page is rendered for thumbnails.
+func (f Format) IsDocument() bool {
+	return f == PDF
+}
+
+// IsAudio reports whether f is an audio format.
+func (f Format) IsAudio() bool {
+	switch f {
+	case MP3, M4A, OGG, Opus, WAV, FLAC:
+		return true
+	}
+	return false
+}
+
+// MayAnimate reports whether files of format f can hold an animation.
+func (f Format) MayAnimate() bool {
+	return f == GIF || f == WebP || f == AVIF
+}
+
+// IsRaw reports whether f is a camera RAW format, which is kept as the
+// original while clients get a display copy made from its embedded preview.
+func (f Format) IsRaw() bool {
+	switch f {
+	case DNG, CR2, CR3, NEF, ARW:
+		return true
+	}
+	return false
+}
+
+// rawExts maps RAW file extensions to the format they name.
+var rawExts = map[string]Format{
+	".dng": DNG,
+	".cr2": CR2,
+	".cr3": CR3,
+	".nef": NEF,
+	".nrw": NEF,
+	".arw": ARW,
+}
+
+// WithName refines a detected TIFF by the file's name: NEF, ARW and some DNG
+// files are indistinguishable from a TIFF by their first bytes.
+func WithName(info Info, filename string) Info {
+	if info.Format != TIFF {
+		return info
+	}
+	if format, ok := rawExts[strings.ToLower(filepath.Ext(filename))]; ok {
+		info.Format = format
+	}
+	return info
+}
+
+// DetectFile sniffs the file at path.
+func DetectFile(path string) (Info, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Info{}, err
+	}
+	defer file.Close()
+	return DetectReader(file)
+}
+
+// DetectReader sniffs the first bytes of r.
+func DetectReader(r io.Reader) (Info, error) {
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return Info{}, err
+	}
+	return Detect(header[:n]), nil
+}
+
+// Detect recognises a format from a file's leading bytes.
+func Detect(header []byte) Info {
+	switch {
+	case bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}):
+		return Info{Format: JPEG}
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return Info{Format: PNG}
+	case bytes.HasPrefix(header, []byte("GIF87a")), bytes.HasPrefix(header, []byte("GIF89a")):
+		return Info{Format: GIF}
+	case len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WEBP")):
+		return Info{Format: WebP}
+	case bytes.HasPrefix(header, []byte("%PDF-")):
+		return Info{Format: PDF}
+	case len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return Info{Format: WAV}
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return Info{Format: FLAC}
+	case bytes.HasPrefix(header, []byte("OggS")):
+		// The first page holds the codec's identification header.
+		if bytes.Contains(header, []byte("OpusHead")) {
+			return Info{Format: Opus}
+		}
+		return Info{Format: OGG}
+	case bytes.HasPrefix(header, []byte("ID3")), isMPEGAudioFrame(header):
+		return Info{Format: MP3}
+	case bytes.HasPrefix(header, []byte("II*\x00")), bytes.HasPrefix(header, []byte("MM\x00*")):
+		return Info{Format: detectTIFF(header)}
+	}
+
+	if major, compatible, ok := ftyp(header); ok {
+		return detectBMFF(major, compatible)
+	}
+	return Info{}
+}
+
+// ftyp reads the brands of an ISO base media file (HEIF, MP4, MOV), whose
+// first box is "ftyp": size, type, major brand, minor version, compatible brands.
+func ftyp(header []byte) (string, []string, bool) {
+	if len(header) < 16 || string(header[4:8]) != "ftyp" {
+		return "", nil, false
+	}
+	size := int(binary.BigEndian.Uint32(header[:4]))
+	if size < 16 || size > len(header) {
+		size = len(header) // a truncated header still names the major brand
+	}
+
+	var compatible []string
+	for i := 16; i+4 <= size; i += 4 {
+		compatible = append(compatible, string(header[i:i+4]))
+	}
+	return string(header[8:12]), compatible, true
+}
+
+var (
+	heicStill    = []string{"heic", "heix", "heim", "heis"}
+	heicSequence = []string{"hevc", "hevx", "hevm", "hevs"}
+)
+
+func detectBMFF(major string, compatible []string) Info {
+	brands := append([]string{major}, compatible...)
+	info := Info{Brand: major}
+
+	switch {
+	case hasAny(brands, "avif", "avis"):
+		info.Format = AVIF
+		info.Sequence = major == "avis"
+	case hasAny(brands, heicStill...) || hasAny(brands, heicSequence...):
+		info.Format = HEIC
+		info.Sequence = hasAny([]string{major}, heicSequence...)
+	case hasAny(brands, "mif1", "msf1"):
+		info.Format = HEIF
+		info.Sequence = major == "msf1"
+	case major == "crx ":
+		info.Format = CR3
+	case major == "M4A " || major == "M4B ":
+		info.Format = M4A
+	case major == "qt  ":
+		info.Format = MOV
+	default:
+		// isom, mp41, mp42, avc1, iso2..., M4V and friends
+		info.Format = MP4
+	}
+	return info
+}
+
+// isMPEGAudioFrame reports whether header starts with an MPEG-1 or MPEG-2
+// layer III frame header, as MP3 files without an ID3 tag do.
+func isMPEGAudioFrame(header []byte) bool {
+	return len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0 && header[1]&0x06 == 0x02
+}
+
+// dngVersionTag is the TIFF tag that marks a DNG file.
+const dngVersionTag = 0xC612
+
+// detectTIFF tells CR2, which says so after the TIFF header, and DNG, whose
+// first IFD has a DNGVersion entry, from other TIFF files.
+func detectTIFF(header []byte) Format {
+	if len(header) >= 10 && string(header[8:10]) == "CR" {
+		return CR2
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if header[0] == 'M' {
+		order = binary.BigEndian
+	}
+	if len(header) < 8 {
+		return TIFF
+	}
+	ifd := int(order.Uint32(header[4:8]))
+	if ifd < 8 || ifd+2 > len(header) {
+		return TIFF
+	}
+	entries := int(order.Uint16(header[ifd:]))
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + 12*i
+		if entry+2 > len(header) {
+			break
+		}
+		if order.Uint16(header[entry:]) == dngVersionTag {
+			return DNG
+		}
+	}
+	return TIFF
+}
+
+func hasAny(brands []string, wanted ...string) bool {
+	for _, brand := range brands {
+		for _, w := range wanted {
+			if brand == w {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ftypBox builds the leading box of an ISO base media file.
+func ftypBox(major string, compatible ...string) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint32(16+4*len(compatible)))
+	buf.WriteString("ftyp" + major)
+	buf.Write([]byte{0, 0, 0, 0})
+	for _, brand := range compatible {
+		buf.WriteString(brand)
+	}
+	// Next box, so the header looks like a real file.
+	buf.Write([]byte{0, 0, 0, 8, 'm', 'e', 't', 'a'})
+	return buf.Bytes()
+}
+
+// tiffHeader builds a little-endian TIFF header whose first IFD has the given tags.
+func tiffHeader(tags ...uint16) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("II*\x00")
+	binary.Write(&buf, binary.LittleEndian, uint32(8))
+	binary.Write(&buf, binary.LittleEndian, uint16(len(tags)))
+	for _, tag := range tags {
+		binary.Write(&buf, binary.LittleEndian, tag)
+		buf.Write(make([]byte, 10))
+	}
+	return buf.Bytes()
+}
+
+func TestDetect(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		header []byte
+		want   Info
+	}{
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0, 0}, Info{Format: JPEG}},
+		{"png", []byte("\x89PNG\r\n\x1a\n...."), Info{Format: PNG}},
+		{"gif", []byte("GIF89a......"), Info{Format: GIF}},
+		{"webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), Info{Format: WebP}},
+		{"iphone heic", ftypBox("heic", "mif1", "MiHE", "miaf", "heic"), Info{Format: HEIC, Brand: "heic"}},
+		{"heic burst", ftypBox("hevc", "msf1", "heic"), Info{Format: HEIC, Brand: "hevc", Sequence: true}},
+		{"generic heif", ftypBox("mif1", "miaf"), Info{Format: HEIF, Brand: "mif1"}},
+		{"heif sequence", ftypBox("msf1", "iso8"), Info{Format: HEIF, Brand: "msf1", Sequence: true}},
+		{"avif", ftypBox("avif", "mif1", "miaf"), Info{Format: AVIF, Brand: "avif"}},
+		{"animated avif", ftypBox("avis", "avif", "msf1"), Info{Format: AVIF, Brand: "avis", Sequence: true}},
+		{"mp4", ftypBox("isom", "iso2", "avc1", "mp41"), Info{Format: MP4, Brand: "isom"}},
+		{"mov", ftypBox("qt  ", "qt  "), Info{Format: MOV, Brand: "qt  "}},
+		{"tiff", tiffHeader(0x0100, 0x0101), Info{Format: TIFF}},
+		{"big-endian tiff", []byte("MM\x00*\x00\x00\x00\x08"), Info{Format: TIFF}},
+		{"dng", tiffHeader(0x0100, 0x010F, dngVersionTag), Info{Format: DNG}},
+		{"cr2", []byte("II*\x00\x10\x00\x00\x00CR\x02\x00"), Info{Format: CR2}},
+		{"cr3", ftypBox("crx ", "crx ", "isom"), Info{Format: CR3, Brand: "crx "}},
+		{"mp3 with id3", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), Info{Format: MP3}},
+		{"bare mp3 frame", []byte{0xFF, 0xFB, 0x90, 0x64}, Info{Format: MP3}},
+		{"adts aac is not mp3", []byte{0xFF, 0xF1, 0x50, 0x80}, Info{}},
+		{"m4a", ftypBox("M4A ", "M4A ", "mp42", "isom"), Info{Format: M4A, Brand: "M4A "}},
+		{"ogg vorbis", []byte("OggS\x00\x02\x00\x00\x01vorbis"), Info{Format: OGG}},
+		{"ogg opus", []byte("OggS\x00\x02\x00\x00OpusHead"), Info{Format: Opus}},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), Info{Format: WAV}},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), Info{Format: FLAC}},
+		{"pdf", []byte("%PDF-1.7\n%\xe2\xe3\xcf\xd3"), Info{Format: PDF}},
+		{"text", []byte("hello world, not an image"), Info{}},
+		{"empty", nil, Info{}},
+	}
+
+	for _, tt := range tests {
+		if got := Detect(tt.header); got != tt.want {
+			t.Errorf("%s: Detect = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFormatProperties(t *testing.T) {
+
+	if HEIC.Ext() != ".heic" || HEIC.MimeType() != "image/heic" || !HEIC.IsImage() || !HEIC.IsHEIF() {
+		t.Errorf("unexpected HEIC properties")
+	}
+	if JPEG.IsHEIF() || MP4.IsImage() || MOV.Ext() != ".mov" {
+		t.Errorf("unexpected JPEG/MP4/MOV properties")
+	}
+	if !M4A.IsAudio() || M4A.IsImage() || MP4.IsAudio() || Opus.Ext() != ".opus" {
+		t.Errorf("unexpected audio properties")
+	}
+	if !PDF.IsDocument() || PDF.IsImage() || PDF.MimeType() != "application/pdf" || JPEG.IsDocument() {
+		t.Errorf("unexpected PDF properties")
+	}
+	if !GIF.MayAnimate() || !WebP.MayAnimate() || JPEG.MayAnimate() || MP4.MayAnimate() {
+		t.Errorf("unexpected MayAnimate results")
+	}
+	if Unknown.Ext() != "" || Unknown.MimeType() != "application/octet-stream" || Unknown.IsImage() {
+		t.Errorf("unexpected Unknown properties")
+	}
+}
+
+func TestWithName(t *testing.T) {
+
+	tiff := Info{Format: TIFF}
+	tests := []struct {
+		info     Info
+		filename string
+		want     Format
+	}{
+		{tiff, "DSC_0001.NEF", NEF},
+		{tiff, "DSC01234.arw", ARW},
+		{tiff, "scan.tif", TIFF},
+		{Info{Format: JPEG}, "DSC_0001.NEF", JPEG}, // the bytes win over the name
+		{Info{Format: CR3}, "IMG_0001.CR3", CR3},
+	}
+	for _, tt := range tests {
+		if got := WithName(tt.info, tt.filename).Format; got != tt.want {
+			t.Errorf("WithName(%s, %s) = %s, want %s", tt.info.Format, tt.filename, got, tt.want)
+		}
+	}
+
+	if !NEF.IsRaw() || !NEF.IsImage() || TIFF.IsRaw() || CR3.Ext() != ".cr3" {
+		t.Errorf("unexpected RAW format properties")
+	}
+}
+
+func TestFindEmbeddedVideo(t *testing.T) {
+
+	jpeg := append([]byte{0xFF, 0xD8, 0xFF, 0xE1}, bytes.Repeat([]byte{0x42}, 100)...)
+	jpeg = append(jpeg, 0xFF, 0xD9)
+	video := append(ftypBox("mp42", "isom"), bytes.Repeat([]byte{0x17}, 50)...)
+	motion := append(append([]byte{}, jpeg...), video...)
+
+	// A HEIC still starts with its own ftyp box, which must be skipped.
+	heic := append(ftypBox("heic", "mif1"), bytes.Repeat([]byte{0x42}, 100)...)
+	heicMotion := append(append([]byte{}, heic...), video...)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		length int64
+		want   int64
+		found  bool
+	}{
+		{"stated length", motion, int64(len(video)), int64(len(jpeg)), true},
+		{"searched", motion, 0, int64(len(jpeg)), true},
+		{"wrong length falls back to search", motion, 7, int64(len(jpeg)), true},
+		{"heic still", heicMotion, 0, int64(len(heic)), true},
+		{"plain jpeg", jpeg, 0, 0, false},
+		{"plain heic", heic, 0, 0, false},
+		{"tiny", []byte{1, 2}, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := FindEmbeddedVideo(tt.data, tt.length)
+			if got != tt.want || found != tt.found {
+				t.Fatalf("FindEmbeddedVideo = %d, %v; want %d, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestExtractEmbeddedVideo(t *testing.T) {
+
+	dir := t.TempDir()
+	video := append(ftypBox("mp42", "isom"), bytes.Repeat([]byte{0x17}, 50)...)
+	still := filepath.Join(dir, "still.jpg")
+	if err := os.WriteFile(still, append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0, 0, 0xFF, 0xD9}, video...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "motion.mp4")
+	if err := ExtractEmbeddedVideo(still, out, 0); err != nil {
+		t.Fatalf("ExtractEmbeddedVideo: %v", err)
+	}
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, video) {
+		t.Fatalf("extracted %d bytes, want the %d-byte video", len(got), len(video))
+	}
+
+	plain := filepath.Join(dir, "plain.jpg")
+	os.WriteFile(plain, []byte{0xFF, 0xD8, 0xFF, 0xE1, 0, 0, 0xFF, 0xD9}, 0644)
+	if err := ExtractEmbeddedVideo(plain, out, 0); !errors.Is(err, ErrNoEmbeddedVideo) {
+		t.Fatalf("expected ErrNoEmbeddedVideo, got %v", err)
+	}
+}
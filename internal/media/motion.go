@@ -0,0 +1,69 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrNoEmbeddedVideo is returned when a motion photo has no video after the still.
+var ErrNoEmbeddedVideo = errors.New("no embedded video")
+
+// FindEmbeddedVideo returns the offset of the MP4 appended to a motion photo.
+// length is the video's size as stated by the still's metadata (Google's
+// MicroVideoOffset or MotionPhoto container directory); when it is 0 or
+// doesn't point at a video, the data is searched for the video's ftyp box,
+// skipping the file's own (a HEIC still starts with one too).
+func FindEmbeddedVideo(data []byte, length int64) (int64, bool) {
+	size := int64(len(data))
+	if length > 0 && length < size && isVideoStart(data[size-length:]) {
+		return size - length, true
+	}
+
+	for from := 8; from < len(data); {
+		i := bytes.Index(data[from:], []byte("ftyp"))
+		if i < 0 {
+			return 0, false
+		}
+		start := from + i - 4
+		if isVideoStart(data[start:]) {
+			return int64(start), true
+		}
+		from += i + 4
+	}
+	return 0, false
+}
+
+// isVideoStart reports whether data begins with a plausible MP4 or MOV ftyp box.
+func isVideoStart(data []byte) bool {
+	if len(data) < 16 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+	if size := binary.BigEndian.Uint32(data[:4]); size < 16 || size > 256 || size%4 != 0 {
+		return false
+	}
+	format := Detect(data).Format
+	return format == MP4 || format == MOV
+}
+
+// ExtractEmbeddedVideo copies the video appended to the motion photo at path
+// into out. length is as for FindEmbeddedVideo.
+func ExtractEmbeddedVideo(path, out string, length int64) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	start, ok := FindEmbeddedVideo(data, length)
+	if !ok {
+		return ErrNoEmbeddedVideo
+	}
+
+	tmp := out + ".tmp"
+	if err := os.WriteFile(tmp, data[start:], 0644); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write embedded video: %w", err)
+	}
+	return os.Rename(tmp, out)
+}
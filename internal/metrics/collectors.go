@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"github.com/mahdi-cpp/upload-service/internal/imagecache"
+	"github.com/mahdi-cpp/upload-service/internal/jobs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// cacheCollector exposes the counters each image cache keeps about itself.
+type cacheCollector struct {
+	caches map[string]*imagecache.Cache
+
+	hits          *prometheus.Desc
+	misses        *prometheus.Desc
+	loadErrors    *prometheus.Desc
+	evictions     *prometheus.Desc
+	invalidations *prometheus.Desc
+	entries       *prometheus.Desc
+	bytes         *prometheus.Desc
+}
+
+// RegisterCaches exports hit, miss and size metrics for the named image caches.
+func RegisterCaches(caches map[string]*imagecache.Cache) error {
+	return prometheus.Register(newCacheCollector(caches))
+}
+
+func newCacheCollector(caches map[string]*imagecache.Cache) *cacheCollector {
+	labels := []string{"cache"}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(namespace+"_image_cache_"+name, help, labels, nil)
+	}
+	return &cacheCollector{
+		caches:        caches,
+		hits:          desc("hits_total", "Image requests served from memory."),
+		misses:        desc("misses_total", "Image requests that read the file from disk."),
+		loadErrors:    desc("load_errors_total", "Image reads that failed."),
+		evictions:     desc("evictions_total", "Entries dropped to stay within the cache limits."),
+		invalidations: desc("invalidations_total", "Entries dropped because the file changed."),
+		entries:       desc("entries", "Images held in the cache."),
+		bytes:         desc("bytes", "Bytes held in the cache."),
+	}
+}
+
+func (cc *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cc.hits
+	ch <- cc.misses
+	ch <- cc.loadErrors
+	ch <- cc.evictions
+	ch <- cc.invalidations
+	ch <- cc.entries
+	ch <- cc.bytes
+}
+
+func (cc *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, cache := range cc.caches {
+		s := cache.Stats()
+		ch <- prometheus.MustNewConstMetric(cc.hits, prometheus.CounterValue, float64(s.Hits), name)
+		ch <- prometheus.MustNewConstMetric(cc.misses, prometheus.CounterValue, float64(s.Misses), name)
+		ch <- prometheus.MustNewConstMetric(cc.loadErrors, prometheus.CounterValue, float64(s.LoadErrors), name)
+		ch <- prometheus.MustNewConstMetric(cc.evictions, prometheus.CounterValue, float64(s.Evictions), name)
+		ch <- prometheus.MustNewConstMetric(cc.invalidations, prometheus.CounterValue, float64(s.Invalidations), name)
+		ch <- prometheus.MustNewConstMetric(cc.entries, prometheus.GaugeValue, float64(s.Entries), name)
+		ch <- prometheus.MustNewConstMetric(cc.bytes, prometheus.GaugeValue, float64(s.Bytes), name)
+	}
+}
+
+// RegisterJobPool exports the occupancy of the processing pool.
+func RegisterJobPool(pool *jobs.Pool) {
+	gauge := func(name, help string, value func(jobs.Stats) float64) {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value(pool.Stats()) })
+	}
+
+	gauge("jobs_running", "Heavy processing jobs currently running.", func(s jobs.Stats) float64 { return float64(s.Running) })
+	gauge("jobs_waiting", "Jobs queued for a processing slot.", func(s jobs.Stats) float64 { return float64(s.Waiting) })
+	gauge("jobs_capacity", "Processing slots available in total.", func(s jobs.Stats) float64 { return float64(s.Capacity) })
+	gauge("jobs_rejected", "Jobs turned away because the pool was saturated.", func(s jobs.Stats) float64 { return float64(s.Rejected) })
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "upload_service"
+
+var (
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"method", "route"})
+
+	UploadBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_bytes_total",
+		Help:      "Bytes received in uploaded files, by media kind.",
+	}, []string{"kind"})
+
+	StageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "processing_stage_duration_seconds",
+		Help:      "Duration of each upload processing stage.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"stage"})
+
+	StageErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "processing_errors_total",
+		Help:      "Failed processing stages.",
+	}, []string{"stage"})
+
+	ProcessSpawns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "external_process_spawns_total",
+		Help:      "External processes started, by binary.",
+	}, []string{"binary"})
+
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_attempts_total",
+		Help:      "Webhook delivery attempts by outcome: delivered, retry or dead.",
+	}, []string{"result"})
+)
+
+// Handler serves the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records the count and latency of every request by route template.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+
+		RequestsTotal.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		RequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// StartStage times a processing stage. Call the returned function with the
+// stage's error (nil on success) when it finishes.
+//
+//	done := metrics.StartStage("exiftool")
+//	metadata, err := exifTool.GetMetadata(path)
+//	done(err)
+func StartStage(stage string) func(error) {
+	start := time.Now()
+	return func(err error) {
+		StageDuration.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+		if err != nil {
+			StageErrors.WithLabelValues(stage).Inc()
+		}
+	}
+}
+
+// Spawned counts one start of an external binary such as ffmpeg or exiftool.
+func Spawned(binary string) {
+	ProcessSpawns.WithLabelValues(binary).Inc()
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/imagecache"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabelsByRoute(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/items/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for _, path := range []string{"/items/1", "/items/2", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(RequestsTotal.WithLabelValues("GET", "/items/:id", "204")); got != 2 {
+		t.Errorf("route requests = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(RequestsTotal.WithLabelValues("GET", "unmatched", "404")); got != 1 {
+		t.Errorf("unmatched requests = %v, want 1", got)
+	}
+}
+
+func TestStartStageCountsErrors(t *testing.T) {
+
+	StartStage("test_ok")(nil)
+	StartStage("test_fail")(errors.New("boom"))
+
+	if got := testutil.ToFloat64(StageErrors.WithLabelValues("test_ok")); got != 0 {
+		t.Errorf("errors for successful stage = %v, want 0", got)
+	}
+	if got := testutil.ToFloat64(StageErrors.WithLabelValues("test_fail")); got != 1 {
+		t.Errorf("errors for failed stage = %v, want 1", got)
+	}
+	if got := testutil.CollectAndCount(StageDuration); got < 2 {
+		t.Errorf("stage histograms = %d, want at least 2", got)
+	}
+}
+
+func TestCacheCollector(t *testing.T) {
+
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.png"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := imagecache.New(dir, 10, 1<<20)
+	ctx := context.Background()
+	cache.LoadImage(ctx, "a.png")
+	cache.LoadImage(ctx, "a.png")
+	cache.LoadImage(ctx, "missing.png")
+
+	collector := newCacheCollector(map[string]*imagecache.Cache{"test": cache})
+	expected := `
+# HELP upload_service_image_cache_hits_total Image requests served from memory.
+# TYPE upload_service_image_cache_hits_total counter
+upload_service_image_cache_hits_total{cache="test"} 1
+# HELP upload_service_image_cache_misses_total Image requests that read the file from disk.
+# TYPE upload_service_image_cache_misses_total counter
+upload_service_image_cache_misses_total{cache="test"} 2
+# HELP upload_service_image_cache_load_errors_total Image reads that failed.
+# TYPE upload_service_image_cache_load_errors_total counter
+upload_service_image_cache_load_errors_total{cache="test"} 1
+# HELP upload_service_image_cache_entries Images held in the cache.
+# TYPE upload_service_image_cache_entries gauge
+upload_service_image_cache_entries{cache="test"} 1
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"upload_service_image_cache_hits_total",
+		"upload_service_image_cache_misses_total",
+		"upload_service_image_cache_load_errors_total",
+		"upload_service_image_cache_entries")
+	if err != nil {
+		t.Error(err)
+	}
+	if got := testutil.CollectAndCount(collector); got != 7 {
+		t.Errorf("cache metrics = %d, want 7", got)
+	}
+}
@@ -0,0 +1,37 @@
+package quota
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/helpers"
+)
+
+// MultipartOverhead allows for form fields and part headers around the file.
+const MultipartOverhead = 1 << 20
+
+// Middleware rejects an upload whose Content-Length already exceeds the largest
+// permitted file or the caller's remaining quota, before the body is read, and
+// caps how much of the body can be read at all. It must run after auth.
+func Middleware(t *Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		length := c.Request.ContentLength
+
+		if maxSize := t.limits.MaxUploadSize(); maxSize > 0 {
+			if length > maxSize+MultipartOverhead {
+				helpers.AbortWithError(c, http.StatusRequestEntityTooLarge, ErrFileTooLarge.Error())
+				return
+			}
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+MultipartOverhead)
+		}
+
+		if userID, ok := helpers.GetUserID(c); ok && length > 0 {
+			if remaining := t.Remaining(userID); remaining >= 0 && length > remaining+MultipartOverhead {
+				helpers.AbortWithError(c, http.StatusInsufficientStorage, ErrQuotaExceeded.Error())
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,265 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/goccy/go-json"
+)
+
+// Kind is the media kind a file size limit applies to.
+type Kind string
+
+const (
+	KindImage    Kind = "image"
+	KindVideo    Kind = "video"
+	KindDocument Kind = "document"
+	KindAudio    Kind = "audio"
+)
+
+var (
+	ErrFileTooLarge  = errors.New("file exceeds the size limit")
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+// Limits configures file size limits and storage quotas. A zero value means unlimited.
+type Limits struct {
+	MaxFileSize     map[Kind]int64   `json:"maxFileSize"`
+	UserQuota       int64            `json:"userQuota"`
+	NamespaceQuotas map[string]int64 `json:"namespaceQuotas,omitempty"` // per user, within a namespace
+}
+
+// MaxUploadSize is the largest file of any kind, used to reject bodies early.
+func (l Limits) MaxUploadSize() int64 {
+	var largest int64
+	for _, size := range l.MaxFileSize {
+		if size == 0 {
+			return 0
+		}
+		largest = max(largest, size)
+	}
+	return largest
+}
+
+// Usage is what one user stores, in bytes.
+type Usage struct {
+	Total      int64            `json:"total"`
+	Files      int              `json:"files"`
+	Namespaces map[string]int64 `json:"namespaces"`
+}
+
+// Tracker keeps per-user usage up to date as uploads are committed and
+// deleted, persisting it to a JSON file after every change.
+type Tracker struct {
+	mu      sync.Mutex
+	path    string
+	limits  Limits
+	usage   map[string]*Usage
+	pending map[string]map[string]int64 // user -> namespace -> bytes reserved by uploads still in progress
+}
+
+func NewTracker(path string, limits Limits) (*Tracker, error) {
+
+	t := &Tracker{
+		path:    path,
+		limits:  limits,
+		usage:   make(map[string]*Usage),
+		pending: make(map[string]map[string]int64),
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("read usage: %w", err)
+	default:
+		if err := json.Unmarshal(data, &t.usage); err != nil {
+			return nil, fmt.Errorf("parse usage %s: %w", path, err)
+		}
+	}
+
+	return t, nil
+}
+
+func (t *Tracker) Limits() Limits {
+	return t.limits
+}
+
+// CheckFileSize rejects a file larger than the limit for its kind.
+func (t *Tracker) CheckFileSize(kind Kind, size int64) error {
+	if limit := t.limits.MaxFileSize[kind]; limit > 0 && size > limit {
+		return fmt.Errorf("%w: %s of %d bytes, limit %d", ErrFileTooLarge, kind, size, limit)
+	}
+	return nil
+}
+
+// Remaining returns how many more bytes userID may store, or -1 when unlimited.
+func (t *Tracker) Remaining(userID string) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.limits.UserQuota == 0 {
+		return -1
+	}
+	return max(0, t.limits.UserQuota-t.get(userID).Total-t.pendingTotal(userID))
+}
+
+// Usage returns a copy of the usage of userID.
+func (t *Tracker) Usage(userID string) Usage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := *t.get(userID)
+	usage.Namespaces = make(map[string]int64, len(usage.Namespaces))
+	for namespace, size := range t.get(userID).Namespaces {
+		usage.Namespaces[namespace] = size
+	}
+	return usage
+}
+
+// Reservation holds quota for an upload until it is committed or cancelled.
+type Reservation struct {
+	tracker   *Tracker
+	userID    string
+	namespace string
+	size      int64
+	done      bool
+}
+
+// Reserve claims size bytes for an upload by userID into namespace.
+func (t *Tracker) Reserve(userID, namespace string, size int64) (*Reservation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := t.get(userID)
+	pending := t.pendingTotal(userID)
+	inNamespace := usage.Namespaces[namespace] + t.pending[userID][namespace]
+
+	if quota := t.limits.UserQuota; quota > 0 && usage.Total+pending+size > quota {
+		return nil, fmt.Errorf("%w: user has %d of %d bytes in use", ErrQuotaExceeded, usage.Total+pending, quota)
+	}
+	if quota := t.limits.NamespaceQuotas[namespace]; quota > 0 && inNamespace+size > quota {
+		return nil, fmt.Errorf("%w: %s has %d of %d bytes in use", ErrQuotaExceeded, namespace, inNamespace, quota)
+	}
+
+	if t.pending[userID] == nil {
+		t.pending[userID] = make(map[string]int64)
+	}
+	t.pending[userID][namespace] += size
+	return &Reservation{tracker: t, userID: userID, namespace: namespace, size: size}, nil
+}
+
+// Commit records the bytes actually stored, which may differ from the reserved
+// amount once renditions are written.
+func (r *Reservation) Commit(stored int64) error {
+	t := r.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if r.done {
+		return nil
+	}
+	r.done = true
+	t.release(r.userID, r.namespace, r.size)
+
+	usage := t.get(r.userID)
+	usage.Total += stored
+	usage.Files++
+	usage.Namespaces[r.namespace] += stored
+
+	return t.save()
+}
+
+// Cancel returns the reserved bytes, e.g. when processing failed.
+func (r *Reservation) Cancel() {
+	t := r.tracker
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if r.done {
+		return
+	}
+	r.done = true
+	t.release(r.userID, r.namespace, r.size)
+}
+
+// Merge records that two of userID's stored files became one, as when a
+// Live Photo's video or a RAW's JPEG is paired into the other upload. The
+// bytes stay counted; the merged asset is removed with its combined size.
+func (t *Tracker) Merge(userID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := t.get(userID)
+	usage.Files = max(0, usage.Files-1)
+
+	return t.save()
+}
+
+// Remove subtracts a deleted file from the usage of userID.
+func (t *Tracker) Remove(userID, namespace string, size int64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	usage := t.get(userID)
+	usage.Total = max(0, usage.Total-size)
+	usage.Files = max(0, usage.Files-1)
+	usage.Namespaces[namespace] = max(0, usage.Namespaces[namespace]-size)
+	if usage.Namespaces[namespace] == 0 {
+		delete(usage.Namespaces, namespace)
+	}
+
+	return t.save()
+}
+
+func (t *Tracker) release(userID, namespace string, size int64) {
+	pending := t.pending[userID]
+	pending[namespace] -= size
+	if pending[namespace] <= 0 {
+		delete(pending, namespace)
+	}
+	if len(pending) == 0 {
+		delete(t.pending, userID)
+	}
+}
+
+// pendingTotal is what userID has reserved across namespaces. The caller holds t.mu.
+func (t *Tracker) pendingTotal(userID string) int64 {
+	var total int64
+	for _, size := range t.pending[userID] {
+		total += size
+	}
+	return total
+}
+
+func (t *Tracker) get(userID string) *Usage {
+	usage, ok := t.usage[userID]
+	if !ok {
+		usage = &Usage{}
+		t.usage[userID] = usage
+	}
+	if usage.Namespaces == nil {
+		usage.Namespaces = make(map[string]int64)
+	}
+	return usage
+}
+
+// save writes the usage file atomically. The caller holds t.mu.
+func (t *Tracker) save() error {
+	data, err := json.MarshalIndent(t.usage, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return err
+	}
+	tempFile := t.path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, t.path)
+}
@@ -0,0 +1,184 @@
+package quota
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/helpers"
+)
+
+const testUser = "018f3a8b-1b32-729a-f7e5-5467c1b2d3e4"
+
+func newTestTracker(t *testing.T) (*Tracker, string) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	tracker, err := NewTracker(path, Limits{
+		MaxFileSize:     map[Kind]int64{KindImage: 100, KindVideo: 1000},
+		UserQuota:       1000,
+		NamespaceQuotas: map[string]int64{"com.iris.messages": 300},
+	})
+	if err != nil {
+		t.Fatalf("NewTracker: %v", err)
+	}
+	return tracker, path
+}
+
+func TestReserveCommitRemove(t *testing.T) {
+
+	tracker, path := newTestTracker(t)
+
+	r, err := tracker.Reserve(testUser, "com.iris.photos", 600)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+
+	// The pending reservation counts against the quota.
+	if _, err := tracker.Reserve(testUser, "com.iris.photos", 500); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("second reservation: got %v, want ErrQuotaExceeded", err)
+	}
+
+	if err := r.Commit(650); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if usage := tracker.Usage(testUser); usage.Total != 650 || usage.Files != 1 || usage.Namespaces["com.iris.photos"] != 650 {
+		t.Errorf("unexpected usage after commit: %+v", usage)
+	}
+	if remaining := tracker.Remaining(testUser); remaining != 350 {
+		t.Errorf("remaining = %d, want 350", remaining)
+	}
+
+	// Usage survives a restart.
+	reloaded, err := NewTracker(path, tracker.Limits())
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if usage := reloaded.Usage(testUser); usage.Total != 650 {
+		t.Errorf("reloaded total = %d, want 650", usage.Total)
+	}
+
+	if err := tracker.Remove(testUser, "com.iris.photos", 650); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if usage := tracker.Usage(testUser); usage.Total != 0 || usage.Files != 0 {
+		t.Errorf("unexpected usage after remove: %+v", usage)
+	}
+}
+
+func TestMerge(t *testing.T) {
+
+	tracker, _ := newTestTracker(t)
+
+	// A Live Photo arrives as two uploads and becomes one asset.
+	for _, size := range []int64{100, 200} {
+		r, err := tracker.Reserve(testUser, "com.iris.photos", size)
+		if err != nil {
+			t.Fatalf("Reserve: %v", err)
+		}
+		if err := r.Commit(size); err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+	}
+	if err := tracker.Merge(testUser); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	if usage := tracker.Usage(testUser); usage.Total != 300 || usage.Files != 1 {
+		t.Errorf("unexpected usage after merge: %+v", usage)
+	}
+
+	if err := tracker.Remove(testUser, "com.iris.photos", 300); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if usage := tracker.Usage(testUser); usage.Total != 0 || usage.Files != 0 {
+		t.Errorf("unexpected usage after removing the pair: %+v", usage)
+	}
+}
+
+func TestNamespaceQuotaAndCancel(t *testing.T) {
+
+	tracker, _ := newTestTracker(t)
+
+	r, err := tracker.Reserve(testUser, "com.iris.messages", 250)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if err := r.Commit(250); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	if _, err := tracker.Reserve(testUser, "com.iris.messages", 100); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("namespace quota: got %v, want ErrQuotaExceeded", err)
+	}
+
+	// Uploads still in progress count against the namespace too.
+	pending, err := tracker.Reserve(testUser, "com.iris.messages", 40)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if _, err := tracker.Reserve(testUser, "com.iris.messages", 40); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("pending namespace quota: got %v, want ErrQuotaExceeded", err)
+	}
+	pending.Cancel()
+
+	r, err = tracker.Reserve(testUser, "com.iris.photos", 700)
+	if err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	r.Cancel()
+	if remaining := tracker.Remaining(testUser); remaining != 750 {
+		t.Errorf("remaining after cancel = %d, want 750", remaining)
+	}
+}
+
+func TestCheckFileSize(t *testing.T) {
+
+	tracker, _ := newTestTracker(t)
+
+	if err := tracker.CheckFileSize(KindImage, 100); err != nil {
+		t.Errorf("image at the limit: %v", err)
+	}
+	if err := tracker.CheckFileSize(KindImage, 101); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("image over the limit: got %v, want ErrFileTooLarge", err)
+	}
+	if err := tracker.CheckFileSize(KindVideo, 101); err != nil {
+		t.Errorf("video under its limit: %v", err)
+	}
+}
+
+func TestMiddlewareRejectsEarly(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+	tracker, _ := newTestTracker(t)
+
+	router := gin.New()
+	router.POST("/media", func(c *gin.Context) {
+		c.Set(helpers.UserIDKey, testUser)
+	}, Middleware(tracker), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func(size int) int {
+		body := strings.NewReader(strings.Repeat("x", size))
+		req := httptest.NewRequest(http.MethodPost, "/media", body)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := send(10); code != http.StatusOK {
+		t.Errorf("small upload: status %d", code)
+	}
+	if code := send(1000 + MultipartOverhead + 1); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload: status %d, want 413", code)
+	}
+
+	r, _ := tracker.Reserve(testUser, "com.iris.photos", 1000)
+	defer r.Cancel()
+	tracker.limits.MaxFileSize = nil
+	if code := send(MultipartOverhead + 10); code != http.StatusInsufficientStorage {
+		t.Errorf("over quota: status %d, want 507", code)
+	}
+}
@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/helpers"
+	"golang.org/x/time/rate"
+)
+
+// idleTimeout is how long an unused bucket is kept before it is dropped.
+const idleTimeout = 10 * time.Minute
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter is a set of token buckets, one per user or client IP.
+type Limiter struct {
+	mu       sync.Mutex
+	rate     rate.Limit
+	burst    int
+	buckets  map[string]*bucket
+	rejected uint64
+}
+
+// NewLimiter allows perSecond requests per key on average, with bursts of up to burst.
+func NewLimiter(perSecond float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate.Limit(perSecond),
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token for key. When none is left it returns false and how
+// long the caller should wait before retrying.
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		l.evictIdle(now)
+		b = &bucket{limiter: rate.NewLimiter(l.rate, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	reservation := b.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		l.rejected++
+		return false, time.Second
+	}
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		l.rejected++
+		return false, delay
+	}
+	return true, 0
+}
+
+type Stats struct {
+	Keys     int     `json:"keys"`
+	Rejected uint64  `json:"rejected"`
+	Rate     float64 `json:"ratePerSecond"`
+	Burst    int     `json:"burst"`
+}
+
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Stats{Keys: len(l.buckets), Rejected: l.rejected, Rate: float64(l.rate), Burst: l.burst}
+}
+
+// evictIdle drops buckets that have not been used for idleTimeout. The caller holds l.mu.
+func (l *Limiter) evictIdle(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware limits requests per authenticated user, or per client IP for
+// anonymous requests, answering 429 with Retry-After when a bucket is empty.
+func Middleware(l *Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := "ip:" + c.ClientIP()
+		if userID, ok := helpers.GetUserID(c); ok {
+			key = "user:" + userID
+		}
+
+		if ok, wait := l.Allow(key, time.Now()); !ok {
+			AbortTooManyRequests(c, wait)
+			return
+		}
+		c.Next()
+	}
+}
+
+// AbortTooManyRequests answers 429 with a Retry-After of at least one second.
+func AbortTooManyRequests(c *gin.Context, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	helpers.AbortWithError(c, http.StatusTooManyRequests, "too many requests")
+}
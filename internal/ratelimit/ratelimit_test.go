@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAllowPerKey(t *testing.T) {
+
+	limiter := NewLimiter(1, 2)
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow("a", now); !ok {
+			t.Fatalf("request %d within burst was rejected", i)
+		}
+	}
+
+	ok, wait := limiter.Allow("a", now)
+	if ok || wait <= 0 {
+		t.Errorf("third request: ok=%v wait=%v, want rejection with a wait", ok, wait)
+	}
+
+	if ok, _ := limiter.Allow("b", now); !ok {
+		t.Errorf("another key should have its own bucket")
+	}
+
+	if ok, _ := limiter.Allow("a", now.Add(time.Second)); !ok {
+		t.Errorf("bucket should refill after a second")
+	}
+}
+
+func TestMiddlewareRetryAfter(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", Middleware(NewLimiter(0.5, 1)), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+
+	if w := send(); w.Code != http.StatusOK {
+		t.Fatalf("first request: status %d", w.Code)
+	}
+	w := send()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "2" {
+		t.Errorf("Retry-After = %q, want 2", w.Header().Get("Retry-After"))
+	}
+}
@@ -0,0 +1,18 @@
+package signing
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// ClaimsKey is the gin context key holding the verified *Claims of a signed URL.
+const ClaimsKey = "signedClaims"
+
+// GetClaims returns the claims of the signed URL used by the request, if any.
+func GetClaims(c *gin.Context) (*Claims, bool) {
+	value, exists := c.Get(ClaimsKey)
+	if !exists {
+		return nil, false
+	}
+	claims, ok := value.(*Claims)
+	return claims, ok
+}
@@ -0,0 +1,191 @@
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query parameters carried by a signed URL.
+const (
+	ParamExpires   = "exp"
+	ParamUserID    = "uid"
+	ParamTransform = "tr"
+	ParamKeyID     = "kid"
+	ParamSignature = "sig"
+)
+
+// TransformParams are the query parameters a signed transform may pin.
+var TransformParams = []string{"w", "h", "fit", "q", "fmt", "color"}
+
+var (
+	ErrMissingSignature = errors.New("signature is missing")
+	ErrInvalidSignature = errors.New("signature is invalid")
+	ErrExpired          = errors.New("signed URL has expired")
+	ErrUnknownKey       = errors.New("signing key is unknown")
+	ErrTransform        = errors.New("transform does not match the signed URL")
+)
+
+// Key is one HMAC secret identified by ID so that keys can be rotated.
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Claims are the facts protected by a signature.
+type Claims struct {
+	Path      string    `json:"path"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	UserID    string    `json:"userId,omitempty"`
+	Transform string    `json:"transform,omitempty"`
+}
+
+// Signer issues and verifies signed URLs. New URLs are signed with the first
+// key; every key is accepted on verification, which lets an old key keep
+// working until its URLs expire.
+type Signer struct {
+	current string
+	keys    map[string][]byte
+}
+
+func NewSigner(keys []Key) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("signing: at least one key is required")
+	}
+
+	signer := &Signer{
+		current: keys[0].ID,
+		keys:    make(map[string][]byte, len(keys)),
+	}
+	for _, key := range keys {
+		if key.ID == "" || len(key.Secret) == 0 {
+			return nil, errors.New("signing: key id and secret must not be empty")
+		}
+		if _, ok := signer.keys[key.ID]; ok {
+			return nil, fmt.Errorf("signing: duplicate key id %q", key.ID)
+		}
+		signer.keys[key.ID] = key.Secret
+	}
+	return signer, nil
+}
+
+// ParseKeys reads keys from "kid:secret,kid:secret". The first key signs.
+func ParseKeys(value string) ([]Key, error) {
+	var keys []Key
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, fmt.Errorf("signing: key %q is not in kid:secret form", id)
+		}
+		keys = append(keys, Key{ID: strings.TrimSpace(id), Secret: []byte(strings.TrimSpace(secret))})
+	}
+	return keys, nil
+}
+
+// RandomKey creates a throwaway key for when none is configured.
+// URLs signed with it stop working when the process restarts.
+func RandomKey() (Key, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, err
+	}
+	return Key{ID: "ephemeral", Secret: secret}, nil
+}
+
+// Sign returns the query parameters that authorise claims.
+func (s *Signer) Sign(claims Claims) url.Values {
+	claims.Transform = CanonicalTransform(claims.Transform)
+
+	values := url.Values{}
+	values.Set(ParamExpires, strconv.FormatInt(claims.ExpiresAt.Unix(), 10))
+	if claims.UserID != "" {
+		values.Set(ParamUserID, claims.UserID)
+	}
+	if claims.Transform != "" {
+		values.Set(ParamTransform, claims.Transform)
+	}
+	values.Set(ParamKeyID, s.current)
+	values.Set(ParamSignature, s.mac(s.keys[s.current], claims))
+	return values
+}
+
+// Verify checks the signature in query against path and returns its claims.
+func (s *Signer) Verify(path string, query url.Values, now time.Time) (*Claims, error) {
+
+	signature := query.Get(ParamSignature)
+	if signature == "" {
+		return nil, ErrMissingSignature
+	}
+
+	secret, ok := s.keys[query.Get(ParamKeyID)]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	expires, err := strconv.ParseInt(query.Get(ParamExpires), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	claims := Claims{
+		Path:      path,
+		ExpiresAt: time.Unix(expires, 0),
+		UserID:    query.Get(ParamUserID),
+		Transform: query.Get(ParamTransform),
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(s.mac(secret, claims))) {
+		return nil, ErrInvalidSignature
+	}
+	if !now.Before(claims.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	if CanonicalTransform(transformOf(query)) != claims.Transform {
+		return nil, ErrTransform
+	}
+
+	return &claims, nil
+}
+
+func (s *Signer) mac(secret []byte, claims Claims) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strings.Join([]string{
+		claims.Path,
+		strconv.FormatInt(claims.ExpiresAt.Unix(), 10),
+		claims.UserID,
+		claims.Transform,
+	}, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// CanonicalTransform normalises a transform query such as "w=540&fit=cover"
+// so that parameter order does not change the signature.
+func CanonicalTransform(transform string) string {
+	values, err := url.ParseQuery(transform)
+	if err != nil {
+		return transform
+	}
+	return transformOf(values)
+}
+
+// transformOf extracts the transform parameters from query in canonical order.
+func transformOf(query url.Values) string {
+	canonical := url.Values{}
+	for _, name := range TransformParams {
+		if value := query.Get(name); value != "" {
+			canonical.Set(name, value)
+		}
+	}
+	return canonical.Encode()
+}
@@ -0,0 +1,122 @@
+package signing
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const testPath = "/api/v1/download/original/com.iris.messages/chats/018f3a8b-1b32-7295-a2c7-87654b4d4567/assets/a.jpg"
+
+func newTestSigner(t *testing.T, keys ...Key) *Signer {
+	signer, err := NewSigner(keys)
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	return signer
+}
+
+func TestSignAndVerify(t *testing.T) {
+
+	signer := newTestSigner(t, Key{ID: "k1", Secret: []byte("secret-one")})
+	now := time.Now()
+
+	query := signer.Sign(Claims{Path: testPath, ExpiresAt: now.Add(time.Hour), UserID: "user-1"})
+
+	claims, err := signer.Verify(testPath, query, now)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.UserID != "user-1" {
+		t.Errorf("user id = %q, want user-1", claims.UserID)
+	}
+
+	if _, err := signer.Verify(testPath+"x", query, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("tampered path: got %v, want ErrInvalidSignature", err)
+	}
+
+	tampered := cloneValues(query)
+	tampered.Set(ParamUserID, "user-2")
+	if _, err := signer.Verify(testPath, tampered, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("tampered user: got %v, want ErrInvalidSignature", err)
+	}
+
+	if _, err := signer.Verify(testPath, query, now.Add(2*time.Hour)); !errors.Is(err, ErrExpired) {
+		t.Errorf("expired: got %v, want ErrExpired", err)
+	}
+
+	if _, err := signer.Verify(testPath, url.Values{}, now); !errors.Is(err, ErrMissingSignature) {
+		t.Errorf("unsigned: got %v, want ErrMissingSignature", err)
+	}
+}
+
+func TestVerifyTransform(t *testing.T) {
+
+	signer := newTestSigner(t, Key{ID: "k1", Secret: []byte("secret-one")})
+	now := time.Now()
+
+	query := signer.Sign(Claims{Path: testPath, ExpiresAt: now.Add(time.Hour), Transform: "w=540&fit=cover"})
+	query.Set("fit", "cover")
+	query.Set("w", "540")
+
+	if _, err := signer.Verify(testPath, query, now); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	query.Set("w", "1080")
+	if _, err := signer.Verify(testPath, query, now); !errors.Is(err, ErrTransform) {
+		t.Errorf("changed transform: got %v, want ErrTransform", err)
+	}
+
+	query.Set("w", "540")
+	query.Set("color", "preserve")
+	if _, err := signer.Verify(testPath, query, now); !errors.Is(err, ErrTransform) {
+		t.Errorf("added color: got %v, want ErrTransform", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+
+	now := time.Now()
+	oldKey := Key{ID: "2024", Secret: []byte("old-secret")}
+	newKey := Key{ID: "2025", Secret: []byte("new-secret")}
+
+	query := newTestSigner(t, oldKey).Sign(Claims{Path: testPath, ExpiresAt: now.Add(time.Hour)})
+
+	rotated := newTestSigner(t, newKey, oldKey)
+	if _, err := rotated.Verify(testPath, query, now); err != nil {
+		t.Errorf("old key should still verify after rotation: %v", err)
+	}
+	if kid := rotated.Sign(Claims{Path: testPath, ExpiresAt: now}).Get(ParamKeyID); kid != "2025" {
+		t.Errorf("new URLs signed with %q, want 2025", kid)
+	}
+
+	retired := newTestSigner(t, newKey)
+	if _, err := retired.Verify(testPath, query, now); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("retired key: got %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+
+	keys, err := ParseKeys("a:one, b:two")
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	if len(keys) != 2 || keys[0].ID != "a" || string(keys[1].Secret) != "two" {
+		t.Errorf("unexpected keys: %+v", keys)
+	}
+
+	if _, err := ParseKeys("missing-separator"); err == nil {
+		t.Errorf("expected an error for a key without a secret")
+	}
+}
+
+func cloneValues(values url.Values) url.Values {
+	clone := url.Values{}
+	for key, value := range values {
+		clone[key] = append([]string(nil), value...)
+	}
+	return clone
+}
@@ -0,0 +1,100 @@
+package thumbnail
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cshum/vipsgen/vips"
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+)
+
+// AnimationInfo describes the frames of an animated image.
+type AnimationInfo struct {
+	Frames   int           // 1 for a still image
+	Loop     int           // times to play; 0 loops forever
+	Duration time.Duration // of one play through
+}
+
+// Animation reads the frame count, loop count and duration of the image at
+// path. libvips loads GIF and WebP animations as pages of one tall image.
+func Animation(path string) (*AnimationInfo, error) {
+	img, err := vips.NewImageFromFile(path, &vips.LoadOptions{N: -1})
+	if err != nil {
+		return nil, fmt.Errorf("animation: load %s: %w", path, err)
+	}
+	defer img.Close()
+
+	info := &AnimationInfo{Frames: max(img.Pages(), 1)}
+	if info.Frames == 1 {
+		return info, nil
+	}
+	if loop, err := img.GetInt("loop"); err == nil {
+		info.Loop = loop
+	}
+	if delays, err := img.PageDelay(); err == nil {
+		for _, delay := range delays {
+			info.Duration += time.Duration(delay) * time.Millisecond
+		}
+	}
+	return info, nil
+}
+
+// AnimationLimits caps how much of an animation a thumbnail keeps.
+type AnimationLimits struct {
+	MaxFrames   int
+	MaxDuration time.Duration
+}
+
+// framesWithin is how many leading frames fit limits, given each frame's delay.
+func framesWithin(delays []int, frames int, limits AnimationLimits) int {
+	n := frames
+	if limits.MaxFrames > 0 {
+		n = min(n, limits.MaxFrames)
+	}
+	if limits.MaxDuration > 0 {
+		var total time.Duration
+		for i := 0; i < n && i < len(delays); i++ {
+			total += time.Duration(delays[i]) * time.Millisecond
+			if total > limits.MaxDuration {
+				return max(i, 1)
+			}
+		}
+	}
+	return n
+}
+
+// AnimatedThumbnail writes an animated WebP of the image at path, width
+// pixels wide, keeping only the leading frames that fit limits.
+func AnimatedThumbnail(ctx context.Context, path, outPath string, width int, limits AnimationLimits, quality int, color Color) error {
+	img, err := vips.NewImageFromFile(path, &vips.LoadOptions{N: -1})
+	if err != nil {
+		return fmt.Errorf("animated thumbnail: load %s: %w", path, err)
+	}
+	delays, _ := img.PageDelay()
+	n := framesWithin(delays, max(img.Pages(), 1), limits)
+	img.Close()
+
+	// vips_thumbnail shrinks every page of a multi-page load consistently.
+	thumbOpts := vips.DefaultThumbnailOptions()
+	thumbOpts.Height = maxCoord
+	thumbOpts.Size = vips.SizeDown
+	thumbOpts.OutputProfile = outputProfile(color)
+	thumb, err := vips.NewThumbnail(fmt.Sprintf("%s[n=%d]", path, n), width, thumbOpts)
+	if err != nil {
+		return fmt.Errorf("animated thumbnail: %w", err)
+	}
+	defer thumb.Close()
+
+	o := vips.DefaultWebpsaveOptions()
+	if quality > 0 {
+		o.Q = quality
+	}
+	o.Keep = vips.KeepIcc
+	if err := thumb.Webpsave(outPath, o); err != nil {
+		return fmt.Errorf("animated thumbnail: save %s: %w", outPath, err)
+	}
+
+	logging.FromContext(ctx).Debug("created animated thumbnail", "file", outPath, "frames", n)
+	return nil
+}
@@ -0,0 +1,50 @@
+package thumbnail
+
+import (
+	"fmt"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// Color says what derivatives do with originals in a wide-gamut space such
+// as Display P3 or Adobe RGB.
+type Color string
+
+const (
+	ColorSRGB     Color = "srgb"     // convert to sRGB, which every client shows correctly
+	ColorPreserve Color = "preserve" // keep the original's pixels and ICC profile
+)
+
+// ParseColor accepts "srgb" and "preserve"; empty is ColorSRGB.
+func ParseColor(value string) (Color, error) {
+	switch c := Color(value); c {
+	case "":
+		return ColorSRGB, nil
+	case ColorSRGB, ColorPreserve:
+		return c, nil
+	}
+	return "", fmt.Errorf("unsupported color %q", value)
+}
+
+// outputProfile is the vips_thumbnail output profile for c. vips_thumbnail
+// converts from the embedded profile, or from sRGB when there is none.
+func outputProfile(c Color) string {
+	if c == ColorPreserve {
+		return ""
+	}
+	return "srgb"
+}
+
+// convertColor converts img from its embedded ICC profile to sRGB, unless c
+// preserves it. Images without a profile are taken to be sRGB already.
+func convertColor(img *vips.Image, c Color) error {
+	if c == ColorPreserve || !img.HasICCProfile() {
+		return nil
+	}
+	o := vips.DefaultIccTransformOptions()
+	o.Embedded = true
+	if err := img.IccTransform("srgb", o); err != nil {
+		return fmt.Errorf("convert to srgb: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package thumbnail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cshum/vipsgen/vips"
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+)
+
+// DisplayInfo describes the original a display derivative was made from.
+type DisplayInfo struct {
+	Width  int // of the primary image, after rotation
+	Height int
+	Pages  int // top-level images in a multi-image container, 1 otherwise
+}
+
+// Display writes a full-resolution copy of originalPath's primary image in
+// format (JPEG or AVIF) for clients that can't decode the original, such as
+// HEIC on most browsers. EXIF, XMP and the ICC profile are kept.
+func Display(ctx context.Context, originalPath, outPath string, format Format, quality int) (*DisplayInfo, error) {
+
+	img, err := vips.NewImageFromFile(originalPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("display: load %s: %w", originalPath, err)
+	}
+	defer img.Close()
+
+	// HEIF decoders apply the container's rotation already; this only acts
+	// on formats that carry an EXIF orientation, and resets the tag to match.
+	if err := img.Autorot(); err != nil {
+		return nil, fmt.Errorf("display: rotate: %w", err)
+	}
+
+	info := &DisplayInfo{Width: img.Width(), Height: img.Height(), Pages: max(img.Pages(), 1)}
+
+	switch format {
+	case FormatAVIF:
+		o := vips.DefaultHeifsaveOptions()
+		o.Compression = vips.HeifCompressionAv1
+		o.Bitdepth = 8
+		if quality > 0 {
+			o.Q = quality
+		}
+		o.Keep = vips.KeepAll
+		err = img.Heifsave(outPath, o)
+	case FormatJPEG:
+		o := vips.DefaultJpegsaveOptions()
+		if quality > 0 {
+			o.Q = quality
+		}
+		o.Keep = vips.KeepAll
+		err = img.Jpegsave(outPath, o)
+	default:
+		return nil, fmt.Errorf("display: unsupported format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("display: save %s: %w", outPath, err)
+	}
+
+	logging.FromContext(ctx).Debug("created display derivative", "file", outPath, "pages", info.Pages)
+	return info, nil
+}
@@ -0,0 +1,82 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/cshum/vipsgen/vips"
+	"github.com/mahdi-cpp/upload-service/internal/logging"
+	"github.com/mahdi-cpp/upload-service/internal/metrics"
+)
+
+// DocumentCover renders the first page of the PDF at path as a JPEG width
+// pixels wide, on white. It uses libvips' PDF loader (poppler or PDFium) and
+// falls back to poppler's pdftoppm when libvips was built without one.
+func DocumentCover(ctx context.Context, path, outPath string, width, quality int) error {
+	vipsErr := vipsCover(path, outPath, width, quality)
+	if vipsErr == nil {
+		return nil
+	}
+	logging.FromContext(ctx).Debug("libvips could not render document, trying pdftoppm", "file", path, "error", vipsErr)
+
+	if err := pdftoppmCover(ctx, path, outPath, width); err != nil {
+		return fmt.Errorf("document cover: %w", errors.Join(vipsErr, err))
+	}
+	return nil
+}
+
+func vipsCover(path, outPath string, width, quality int) error {
+	// vips_thumbnail renders the page at the target scale rather than
+	// rasterising at full size first.
+	thumbOpts := vips.DefaultThumbnailOptions()
+	thumbOpts.Height = maxCoord
+	img, err := vips.NewThumbnail(path, width, thumbOpts)
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+
+	if img.HasAlpha() {
+		o := vips.DefaultFlattenOptions()
+		o.Background = []float64{255, 255, 255}
+		if err := img.Flatten(o); err != nil {
+			return err
+		}
+	}
+
+	o := vips.DefaultJpegsaveOptions()
+	if quality > 0 {
+		o.Q = quality
+	}
+	o.Keep = vips.KeepNone
+	return img.Jpegsave(outPath, o)
+}
+
+func pdftoppmCover(ctx context.Context, path, outPath string, width int) error {
+	pdftoppm, err := exec.LookPath("pdftoppm")
+	if err != nil {
+		return fmt.Errorf("pdftoppm not found in PATH: %w", err)
+	}
+
+	// pdftoppm appends the extension to the output root itself.
+	args := []string{
+		"-jpeg", "-singlefile",
+		"-f", "1", "-l", "1",
+		"-scale-to-x", strconv.Itoa(width), "-scale-to-y", "-1",
+		path, strings.TrimSuffix(outPath, ".jpg"),
+	}
+	cmd := exec.CommandContext(ctx, pdftoppm, args...)
+	metrics.Spawned("pdftoppm")
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pdftoppm: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package thumbnail
+
+import (
+	"fmt"
+
+	"github.com/cshum/vipsgen/vips"
+)
+
+// Fit controls how a rendered image is fitted into the requested box.
+type Fit string
+
+const (
+	FitContain   Fit = "contain"   // scale down to fit inside the box, keep aspect ratio
+	FitCover     Fit = "cover"     // fill the box and crop the centre
+	FitAttention Fit = "attention" // fill the box and crop the most interesting region
+	FitFill      Fit = "fill"      // stretch to exactly the box, ignoring aspect ratio
+)
+
+// Format is an output encoding for rendered images.
+type Format string
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatWebP Format = "webp"
+	FormatAVIF Format = "avif"
+	FormatPNG  Format = "png"
+)
+
+// maxCoord stands in for an unconstrained dimension when only one side is requested.
+const maxCoord = 10000000
+
+// RenderOptions describes a variant derived on the fly from an original image.
+type RenderOptions struct {
+	Width   int
+	Height  int
+	Fit     Fit
+	Quality int
+	Format  Format
+	Color   Color
+}
+
+// Render derives a resized variant of originalPath and returns the encoded bytes.
+func Render(originalPath string, opts RenderOptions) ([]byte, error) {
+
+	if opts.Width <= 0 && opts.Height <= 0 {
+		return nil, fmt.Errorf("render: width or height is required")
+	}
+
+	// Crop and force need a real box on both sides.
+	if opts.Fit != FitContain && opts.Fit != "" && (opts.Width <= 0 || opts.Height <= 0) {
+		return nil, fmt.Errorf("render: fit %q needs both width and height", opts.Fit)
+	}
+
+	width := opts.Width
+	thumbOpts := vips.DefaultThumbnailOptions()
+	thumbOpts.Height = opts.Height
+	thumbOpts.OutputProfile = outputProfile(opts.Color)
+	if width <= 0 {
+		width = maxCoord
+	}
+	if thumbOpts.Height <= 0 {
+		thumbOpts.Height = maxCoord
+	}
+
+	switch opts.Fit {
+	case FitCover:
+		thumbOpts.Crop = vips.InterestingCentre
+	case FitAttention:
+		thumbOpts.Crop = vips.InterestingAttention
+	case FitFill:
+		thumbOpts.Size = vips.SizeForce
+	default:
+		thumbOpts.Size = vips.SizeDown
+	}
+
+	img, err := vips.NewThumbnail(originalPath, width, thumbOpts)
+	if err != nil {
+		return nil, fmt.Errorf("render: thumbnail %s: %w", originalPath, err)
+	}
+	defer img.Close()
+
+	return encode(img, opts.Format, opts.Quality)
+}
+
+// encode writes img in the requested format, stripping metadata from the output.
+func encode(img *vips.Image, format Format, quality int) ([]byte, error) {
+	switch format {
+	case FormatWebP:
+		o := vips.DefaultWebpsaveBufferOptions()
+		if quality > 0 {
+			o.Q = quality
+		}
+		o.Keep = vips.KeepIcc
+		return img.WebpsaveBuffer(o)
+	case FormatAVIF:
+		o := vips.DefaultHeifsaveBufferOptions()
+		o.Compression = vips.HeifCompressionAv1
+		o.Bitdepth = 8
+		if quality > 0 {
+			o.Q = quality
+		}
+		o.Keep = vips.KeepIcc
+		return img.HeifsaveBuffer(o)
+	case FormatPNG:
+		o := vips.DefaultPngsaveBufferOptions()
+		o.Keep = vips.KeepIcc
+		return img.PngsaveBuffer(o)
+	default:
+		o := vips.DefaultJpegsaveBufferOptions()
+		if quality > 0 {
+			o.Q = quality
+		}
+		o.Keep = vips.KeepIcc
+		return img.JpegsaveBuffer(o)
+	}
+}
@@ -1,8 +1,9 @@
 package thumbnail
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -10,6 +11,7 @@ import (
 	"sync"
 
 	"github.com/cshum/vipsgen/vips"
+	"github.com/mahdi-cpp/upload-service/internal/logging"
 )
 
 const userID = "018f3a8b-1b32-729a-f7e5-5467c1b2d3e4"
@@ -35,7 +37,7 @@ func CreateSingleThumbnail(src string, fileName string) error {
 	}
 
 	if err := ProcessImage(src, "app/tmp/ali/"+fileName, 270); err != nil {
-		log.Printf("failed create single thumbnail %s: %v", src, err)
+		slog.Error("failed to create single thumbnail", "file", src, "error", err)
 	}
 
 	return nil
@@ -66,7 +68,7 @@ func CreateThumbnails() error {
 				fileName := filepath.Base(filePath)
 				dest := filepath.Join(thumbPath, fileName)
 				if err := ProcessImage(filePath, dest, 270); err != nil {
-					log.Printf("Error processing %s: %v", fileName, err)
+					slog.Error("failed to create thumbnail", "file", fileName, "error", err)
 				}
 			}
 		}()
@@ -107,7 +109,7 @@ func ProcessImage(originalPath string, thumbPath string, targetWidth int) error
 	defer img.Close()
 	defer source.Close()
 
-	fmt.Println("img orientation ", img.Orientation())
+	slog.Debug("image orientation", "file", originalPath, "orientation", img.Orientation())
 	var width = 0
 	var height = 0
 	if img.Orientation() == 6 {
@@ -129,7 +131,6 @@ func ProcessImage(originalPath string, thumbPath string, targetWidth int) error
 	// Resize the img
 	err = img.Resize(scale, &vips.ResizeOptions{Kernel: vips.KernelNearest})
 	if err != nil {
-		fmt.Println("1", err.Error())
 		return fmt.Errorf("failed to resize img: %w", err)
 	}
 
@@ -163,8 +164,7 @@ func ProcessImage(originalPath string, thumbPath string, targetWidth int) error
 
 	err = img.Jpegsave(thumbPath, &vips.JpegsaveOptions{})
 	if err != nil {
-		fmt.Println("error save jpeg", err.Error())
-		return err
+		return fmt.Errorf("failed to save jpeg: %w", err)
 	}
 	//}
 
@@ -198,11 +198,14 @@ func ProcessImage(originalPath string, thumbPath string, targetWidth int) error
 	//	return fmt.Errorf("failed to save img: %w", err)
 	//}
 
-	log.Printf("Successfully created thumbnail for %s", filepath.Base(originalPath))
+	slog.Debug("created thumbnail", "file", filepath.Base(originalPath))
 	return nil
 }
 
-func ProcessImage2(originalPath string, thumbPath string, targetWidth int) error {
+// ProcessImage2 writes thumbPath_<targetWidth>.jpg, converting wide-gamut
+// originals to sRGB unless color preserves them.
+func ProcessImage2(ctx context.Context, originalPath string, thumbPath string, targetWidth int, color Color) error {
+	logger := logging.FromContext(ctx)
 
 	// First get img dimensions to determine orientation
 	file, err := os.Open(originalPath)
@@ -224,7 +227,7 @@ func ProcessImage2(originalPath string, thumbPath string, targetWidth int) error
 	defer img.Close()
 	defer source.Close()
 
-	fmt.Println("img orientation ", img.Orientation())
+	logger.Debug("image orientation", "file", originalPath, "orientation", img.Orientation())
 	var width = 0
 	var height = 0
 	if img.Orientation() == 6 {
@@ -246,10 +249,13 @@ func ProcessImage2(originalPath string, thumbPath string, targetWidth int) error
 	// Resize the img
 	err = img.Resize(scale, &vips.ResizeOptions{Kernel: vips.KernelNearest})
 	if err != nil {
-		fmt.Println("1", err.Error())
 		return fmt.Errorf("failed to resize img: %w", err)
 	}
 
+	if err := convertColor(img, color); err != nil {
+		return err
+	}
+
 	// Check if the filename has a .heic extension.
 	if strings.HasSuffix(thumbPath, ".heic") {
 		// Replace the extension with .jpg.
@@ -257,12 +263,13 @@ func ProcessImage2(originalPath string, thumbPath string, targetWidth int) error
 	}
 
 	des := thumbPath + "_" + strconv.Itoa(targetWidth) + ".jpg"
-	err = img.Jpegsave(des, &vips.JpegsaveOptions{})
+	// The ICC profile is kept: without it, a preserved P3 thumbnail shows
+	// dull, and an sRGB one loses nothing.
+	err = img.Jpegsave(des, &vips.JpegsaveOptions{Keep: vips.KeepIcc})
 	if err != nil {
-		fmt.Println("error save jpeg", err.Error())
-		return err
+		return fmt.Errorf("failed to save jpeg: %w", err)
 	}
 
-	log.Printf("Successfully created thumbnail for %s", filepath.Base(originalPath))
+	logger.Debug("created thumbnail", "file", filepath.Base(originalPath), "width", targetWidth)
 	return nil
 }
@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mahdi-cpp/upload-service/internal/config"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/mahdi-cpp/upload-service"
+
+// Setup installs the global tracer provider chosen by config.TraceExporterEnv
+// ("otlp", "stdout" or "none") and the W3C trace context propagator.
+// The returned function flushes and stops the exporter.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch name := strings.ToLower(os.Getenv(config.TraceExporterEnv)); name {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		// Endpoint and headers come from the standard OTEL_EXPORTER_OTLP_* variables.
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing: create exporter: %w", err)
+	}
+
+	provider := NewProvider(exporter)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewProvider batches spans to exporter under this service's resource.
+func NewProvider(exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(config.ServiceName),
+	)
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+}
+
+// Middleware starts a server span for every request, continuing any trace
+// context the client sent.
+func Middleware() gin.HandlerFunc {
+	return otelgin.Middleware(config.ServiceName)
+}
+
+// Start opens a child span of whatever span ctx carries. Call the returned
+// function with the operation's error (nil on success) to end it.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// TraceID returns the ID of the trace ctx belongs to, or "" when it has none.
+func TraceID(ctx context.Context) string {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.HasTraceID() {
+		return ""
+	}
+	return spanContext.TraceID().String()
+}
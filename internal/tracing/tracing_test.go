@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupTest(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return exporter
+}
+
+func TestStartRecordsError(t *testing.T) {
+
+	exporter := setupTest(t)
+
+	_, end := Start(context.Background(), "exiftool")
+	end(errors.New("boom"))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Name != "exiftool" || spans[0].Status.Code != codes.Error {
+		t.Errorf("unexpected span %s with status %v", spans[0].Name, spans[0].Status.Code)
+	}
+}
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+
+	exporter := setupTest(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.POST("/upload", func(c *gin.Context) {
+		_, end := Start(c.Request.Context(), "save")
+		end(nil)
+		c.Status(http.StatusOK)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodPost, "/upload", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected request and stage spans, got %d", len(spans))
+	}
+	for _, span := range spans {
+		if got := span.SpanContext.TraceID().String(); got != traceID {
+			t.Errorf("span %s has trace %s, want %s", span.Name, got, traceID)
+		}
+	}
+	if spans[0].Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Errorf("stage span should be a child of the request span")
+	}
+}
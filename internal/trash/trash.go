@@ -0,0 +1,286 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/assets"
+	"github.com/mahdi-cpp/upload-service/internal/events"
+	"github.com/mahdi-cpp/upload-service/internal/quota"
+)
+
+var (
+	ErrBusy       = errors.New("asset is still processing")
+	ErrNotTrashed = errors.New("asset is not in the trash")
+	ErrConflict   = errors.New("a file already exists at the restore location")
+)
+
+// Bin moves deleted assets into <dir>/<owner>/<assetID>/ and keeps them
+// there for the retention period, after which Purge removes them for good.
+type Bin struct {
+	dir        string
+	retention  time.Duration
+	assets     *assets.Store
+	quota      *quota.Tracker
+	events     events.Publisher
+	invalidate func(paths ...string)
+	now        func() time.Time
+
+	// mu serialises file moves so concurrent requests can't interleave them.
+	mu sync.Mutex
+}
+
+// NewBin calls invalidate with the former paths of every asset it trashes,
+// so caches stop serving them, and publishes an event for every move.
+func NewBin(dir string, retention time.Duration, store *assets.Store, tracker *quota.Tracker, publisher events.Publisher, invalidate func(paths ...string)) *Bin {
+	return &Bin{
+		dir:        dir,
+		retention:  retention,
+		assets:     store,
+		quota:      tracker,
+		events:     publisher,
+		invalidate: invalidate,
+		now:        time.Now,
+	}
+}
+
+// Trash moves the asset's original, renditions and metadata sidecar to the trash.
+func (b *Bin) Trash(id uuid.UUID) (*assets.Asset, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	asset, err := b.assets.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	switch asset.Status {
+	case assets.StatusTrashed:
+		return asset, nil
+	case assets.StatusProcessing:
+		return nil, ErrBusy
+	}
+
+	trashDir := b.assetDir(asset)
+	if err := os.MkdirAll(trashDir, 0755); err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string)
+	var sources []string
+	for _, path := range append(asset.Files(), sidecar(asset.Original)) {
+		if _, err := os.Stat(path); err != nil {
+			continue // failed uploads may lack renditions
+		}
+		target := filepath.Join(trashDir, filepath.Base(path))
+		if err := os.Rename(path, target); err != nil {
+			restoreFiles(files)
+			return nil, fmt.Errorf("trash %s: %w", filepath.Base(path), err)
+		}
+		files[target] = path
+		sources = append(sources, path)
+	}
+
+	now := b.now().UTC()
+	asset, err = b.assets.Update(id, func(a *assets.Asset) error {
+		a.Trash = &assets.TrashInfo{
+			TrashedAt:      now,
+			ExpiresAt:      now.Add(b.retention),
+			PreviousStatus: a.Status,
+			Files:          files,
+		}
+		a.Status = assets.StatusTrashed
+		return nil
+	})
+	if err != nil {
+		restoreFiles(files)
+		return nil, err
+	}
+
+	b.invalidate(sources...)
+	b.publish(events.AssetDeleted, asset)
+	return asset, nil
+}
+
+// TrashDirectory trashes every asset of owner still in upload directory dir.
+// Committed assets have left the directory and are not touched.
+func (b *Bin) TrashDirectory(owner string, dir uuid.UUID) (int, error) {
+	q := assets.Query{
+		Owner:     owner,
+		Directory: dir,
+		Statuses:  []assets.Status{assets.StatusProcessing, assets.StatusReady, assets.StatusFailed},
+		Limit:     assets.DefaultLimit,
+	}
+
+	var ids []uuid.UUID
+	for {
+		page, err := b.assets.List(q)
+		if err != nil {
+			return 0, err
+		}
+		for _, asset := range page.Assets {
+			if asset.Status == assets.StatusProcessing {
+				return 0, ErrBusy
+			}
+			ids = append(ids, asset.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	for i, id := range ids {
+		if _, err := b.Trash(id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+// Restore moves a trashed asset's files back and reinstates its status.
+func (b *Bin) Restore(id uuid.UUID) (*assets.Asset, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	asset, err := b.assets.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if asset.Status != assets.StatusTrashed || asset.Trash == nil {
+		return nil, ErrNotTrashed
+	}
+
+	for _, original := range asset.Trash.Files {
+		if _, err := os.Lstat(original); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrConflict, filepath.Base(original))
+		}
+	}
+
+	moved := make(map[string]string)
+	for trashPath, original := range asset.Trash.Files {
+		err := os.MkdirAll(filepath.Dir(original), 0755)
+		if err == nil {
+			err = os.Rename(trashPath, original)
+		}
+		if err != nil {
+			restoreFiles(moved)
+			return nil, fmt.Errorf("restore %s: %w", filepath.Base(original), err)
+		}
+		moved[original] = trashPath
+	}
+
+	asset, err = b.assets.Update(id, func(a *assets.Asset) error {
+		a.Status = a.Trash.PreviousStatus
+		a.Trash = nil
+		return nil
+	})
+	if err != nil {
+		restoreFiles(moved)
+		return nil, err
+	}
+
+	os.Remove(b.assetDir(asset))
+	b.publish(events.AssetRestored, asset)
+	return asset, nil
+}
+
+// Purge permanently removes trashed assets whose retention has expired and
+// releases their storage quota. It returns how many were removed.
+func (b *Bin) Purge() (int, error) {
+	now := b.now()
+	q := assets.Query{Statuses: []assets.Status{assets.StatusTrashed}, Ascending: true}
+
+	var expired []*assets.Asset
+	for {
+		page, err := b.assets.List(q)
+		if err != nil {
+			return 0, err
+		}
+		for _, asset := range page.Assets {
+			if asset.Trash != nil && !now.Before(asset.Trash.ExpiresAt) {
+				expired = append(expired, asset)
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	purged := 0
+	for _, candidate := range expired {
+		// It may have been restored since the listing.
+		asset, err := b.assets.Get(candidate.ID)
+		if err != nil || asset.Status != assets.StatusTrashed || asset.Trash == nil || now.Before(asset.Trash.ExpiresAt) {
+			continue
+		}
+		if err := os.RemoveAll(b.assetDir(asset)); err != nil {
+			return purged, err
+		}
+		if asset.Size > 0 {
+			if err := b.quota.Remove(asset.Owner, asset.Namespace, asset.Size); err != nil {
+				return purged, err
+			}
+		}
+		if err := b.assets.Delete(asset.ID); err != nil && !errors.Is(err, assets.ErrNotFound) {
+			return purged, err
+		}
+		b.publish(events.AssetPurged, asset)
+		purged++
+	}
+	return purged, nil
+}
+
+// Run purges expired trash every interval until ctx is done.
+func (b *Bin) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := b.Purge()
+			if err != nil {
+				slog.Error("failed to purge trash", "error", err)
+			} else if purged > 0 {
+				slog.Info("purged expired trash", "assets", purged)
+			}
+		}
+	}
+}
+
+// publish reports a change; the asset has already changed, so a failure is only logged.
+func (b *Bin) publish(t events.Type, asset *assets.Asset) {
+	if err := b.events.Publish(context.Background(), events.New(t, asset)); err != nil {
+		slog.Error("failed to publish event", "type", t, "asset_id", asset.ID, "error", err)
+	}
+}
+
+func (b *Bin) assetDir(asset *assets.Asset) string {
+	return filepath.Join(b.dir, asset.Owner, asset.ID.String())
+}
+
+// sidecar is the metadata JSON written next to an original, if any.
+func sidecar(original string) string {
+	return strings.TrimSuffix(original, filepath.Ext(original)) + ".json"
+}
+
+// restoreFiles undoes moves recorded as destination to source.
+func restoreFiles(moves map[string]string) {
+	for to, from := range moves {
+		os.Rename(to, from)
+	}
+}
@@ -0,0 +1,169 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/assets"
+	"github.com/mahdi-cpp/upload-service/internal/events"
+	"github.com/mahdi-cpp/upload-service/internal/quota"
+)
+
+const testUser = "018f3a8b-1b32-729a-f7e5-5467c1b2d3e4"
+
+type fixture struct {
+	bin         *Bin
+	store       *assets.Store
+	tracker     *quota.Tracker
+	invalidated []string
+	events      []events.Event
+	workDir     string
+}
+
+func newFixture(t *testing.T) *fixture {
+	dir := t.TempDir()
+	store, err := assets.Open(filepath.Join(dir, "assets.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	tracker, err := quota.NewTracker(filepath.Join(dir, "usage.json"), quota.Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fixture{store: store, tracker: tracker, workDir: filepath.Join(dir, "uploads")}
+	f.bin = NewBin(filepath.Join(dir, "trash"), time.Hour, store, tracker, f, func(paths ...string) {
+		f.invalidated = append(f.invalidated, paths...)
+	})
+	return f
+}
+
+func (f *fixture) Publish(ctx context.Context, event events.Event) error {
+	f.events = append(f.events, event)
+	return nil
+}
+
+// addAsset writes an original, a rendition and a sidecar and records them as a ready asset.
+func (f *fixture) addAsset(t *testing.T, directory uuid.UUID) *assets.Asset {
+	id := uuid.New()
+	original := filepath.Join(f.workDir, id.String()+".jpg")
+	rendition := filepath.Join(f.workDir, id.String()+"_270.jpg")
+	os.MkdirAll(f.workDir, 0755)
+	for _, path := range []string{original, rendition, sidecar(original)} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	asset := &assets.Asset{
+		ID:         id,
+		Owner:      testUser,
+		Namespace:  "com.iris.photos",
+		Directory:  directory,
+		Status:     assets.StatusReady,
+		Original:   original,
+		Renditions: map[string]string{"270": rendition},
+		Size:       12,
+	}
+	if err := f.store.Put(asset); err != nil {
+		t.Fatal(err)
+	}
+	reservation, _ := f.tracker.Reserve(testUser, asset.Namespace, asset.Size)
+	reservation.Commit(asset.Size)
+	return asset
+}
+
+func TestTrashAndRestore(t *testing.T) {
+
+	f := newFixture(t)
+	asset := f.addAsset(t, uuid.New())
+
+	trashed, err := f.bin.Trash(asset.ID)
+	if err != nil {
+		t.Fatalf("Trash: %v", err)
+	}
+	if trashed.Status != assets.StatusTrashed || len(trashed.Trash.Files) != 3 {
+		t.Fatalf("unexpected trashed asset %+v", trashed)
+	}
+	if _, err := os.Stat(asset.Original); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("original should have moved to the trash")
+	}
+	if len(f.invalidated) != 3 {
+		t.Errorf("expected 3 invalidated paths, got %v", f.invalidated)
+	}
+
+	restored, err := f.bin.Restore(asset.ID)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if restored.Status != assets.StatusReady || restored.Trash != nil {
+		t.Errorf("unexpected restored asset %+v", restored)
+	}
+	for _, path := range []string{asset.Original, asset.Renditions["270"], sidecar(asset.Original)} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected %s to be back: %v", path, err)
+		}
+	}
+
+	if len(f.events) != 2 || f.events[0].Type != events.AssetDeleted || f.events[1].Type != events.AssetRestored {
+		t.Errorf("unexpected events %+v", f.events)
+	}
+
+	if _, err := f.bin.Restore(asset.ID); !errors.Is(err, ErrNotTrashed) {
+		t.Errorf("expected ErrNotTrashed, got %v", err)
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+
+	f := newFixture(t)
+	asset := f.addAsset(t, uuid.New())
+	if _, err := f.bin.Trash(asset.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if purged, err := f.bin.Purge(); err != nil || purged != 0 {
+		t.Fatalf("Purge before expiry = %d, %v", purged, err)
+	}
+
+	f.bin.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if purged, err := f.bin.Purge(); err != nil || purged != 1 {
+		t.Fatalf("Purge after expiry = %d, %v", purged, err)
+	}
+	if _, err := f.store.Get(asset.ID); !errors.Is(err, assets.ErrNotFound) {
+		t.Errorf("record should be gone, got %v", err)
+	}
+	if usage := f.tracker.Usage(testUser); usage.Total != 0 || usage.Files != 0 {
+		t.Errorf("quota not released: %+v", usage)
+	}
+	if _, err := os.Stat(f.bin.assetDir(asset)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("trash directory should be removed")
+	}
+	if last := f.events[len(f.events)-1]; last.Type != events.AssetPurged || last.AssetID != asset.ID {
+		t.Errorf("expected a purge event, got %+v", last)
+	}
+}
+
+func TestTrashDirectory(t *testing.T) {
+
+	f := newFixture(t)
+	directory := uuid.New()
+	f.addAsset(t, directory)
+	f.addAsset(t, directory)
+	other := f.addAsset(t, uuid.New())
+
+	trashed, err := f.bin.TrashDirectory(testUser, directory)
+	if err != nil || trashed != 2 {
+		t.Fatalf("TrashDirectory = %d, %v", trashed, err)
+	}
+	if asset, _ := f.store.Get(other.ID); asset.Status != assets.StatusReady {
+		t.Errorf("asset in another directory was touched")
+	}
+}
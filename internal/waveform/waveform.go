@@ -0,0 +1,118 @@
+// Package waveform reduces decoded audio to the peaks a player draws and
+// renders them as a PNG.
+package waveform
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// Analyzer is an io.Writer for mono signed 16-bit little-endian PCM, as
+// ffmpeg.DecodePCM writes it. It keeps only the loudest sample of every
+// block, so hours of audio cost a few hundred kilobytes.
+type Analyzer struct {
+	blockSamples int
+	blocks       []int32 // loudest |sample| of each finished block
+	current      int32
+	inBlock      int
+	carry        []byte // half a sample left over from the previous write
+}
+
+// NewAnalyzer keeps one value per blockSamples samples.
+func NewAnalyzer(blockSamples int) *Analyzer {
+	return &Analyzer{blockSamples: max(blockSamples, 1)}
+}
+
+func (a *Analyzer) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(a.carry) > 0 {
+		p = append(a.carry, p...)
+		a.carry = nil
+	}
+	for ; len(p) >= 2; p = p[2:] {
+		sample := int32(int16(binary.LittleEndian.Uint16(p)))
+		if sample < 0 {
+			sample = -sample
+		}
+		a.current = max(a.current, sample)
+		if a.inBlock++; a.inBlock == a.blockSamples {
+			a.flush()
+		}
+	}
+	if len(p) == 1 {
+		a.carry = []byte{p[0]}
+	}
+	return n, nil
+}
+
+func (a *Analyzer) flush() {
+	a.blocks = append(a.blocks, a.current)
+	a.current, a.inBlock = 0, 0
+}
+
+// Peaks reduces the audio to n values from 0 to 100, each the loudest point
+// of its stretch, scaled so the loudest point overall is 100. Audio shorter
+// than n blocks repeats blocks; silence is all zeros. It returns nil when no
+// audio was written.
+func (a *Analyzer) Peaks(n int) []int {
+	if a.inBlock > 0 {
+		a.flush()
+	}
+	if len(a.blocks) == 0 || n <= 0 {
+		return nil
+	}
+
+	var loudest int32
+	for _, block := range a.blocks {
+		loudest = max(loudest, block)
+	}
+
+	peaks := make([]int, n)
+	for i := range peaks {
+		start := i * len(a.blocks) / n
+		end := max((i+1)*len(a.blocks)/n, start+1)
+		var peak int32
+		for _, block := range a.blocks[start:end] {
+			peak = max(peak, block)
+		}
+		if loudest > 0 {
+			peaks[i] = int((int64(peak)*100 + int64(loudest)/2) / int64(loudest))
+		}
+	}
+	return peaks
+}
+
+// Render draws peaks as vertical bars centred on a transparent width x height
+// image. Bars are at least one pixel tall, so silence shows as a line.
+func Render(peaks []int, width, height int, fg color.Color) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	if len(peaks) == 0 {
+		return img
+	}
+
+	slot := float64(width) / float64(len(peaks))
+	gap := 0
+	if slot >= 3 {
+		gap = 1 // separate bars once they are wide enough to
+	}
+	for i, peak := range peaks {
+		x0 := int(float64(i) * slot)
+		x1 := max(int(float64(i+1)*slot)-gap, x0+1)
+		bar := max(min(peak, 100)*height/100, 1)
+		y0 := (height - bar) / 2
+		for x := x0; x < x1 && x < width; x++ {
+			for y := y0; y < y0+bar; y++ {
+				img.Set(x, y, fg)
+			}
+		}
+	}
+	return img
+}
+
+// WritePNG renders peaks and encodes the image as PNG.
+func WritePNG(w io.Writer, peaks []int, width, height int, fg color.Color) error {
+	return png.Encode(w, Render(peaks, width, height, fg))
+}
@@ -0,0 +1,80 @@
+package waveform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func pcm(samples ...int16) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, samples)
+	return buf.Bytes()
+}
+
+func TestPeaks(t *testing.T) {
+
+	a := NewAnalyzer(2)
+	data := pcm(100, -200, 0, 50, -1000, 10, 500, 0)
+
+	// Split mid-sample to check the carry-over.
+	a.Write(data[:3])
+	a.Write(data[3:])
+
+	// Blocks: 200, 50, 1000, 500.
+	if got := a.Peaks(2); len(got) != 2 || got[0] != 20 || got[1] != 100 {
+		t.Errorf("Peaks(2) = %v, want [20 100]", got)
+	}
+	if got := a.Peaks(8); len(got) != 8 || got[0] != 20 || got[7] != 50 {
+		t.Errorf("Peaks(8) = %v, want blocks repeated", got)
+	}
+}
+
+func TestPeaksEdgeCases(t *testing.T) {
+
+	if got := NewAnalyzer(4).Peaks(10); got != nil {
+		t.Errorf("no audio should give nil peaks, got %v", got)
+	}
+
+	silent := NewAnalyzer(2)
+	silent.Write(pcm(0, 0, 0, 0))
+	if got := silent.Peaks(2); got[0] != 0 || got[1] != 0 {
+		t.Errorf("silence should be zeros, got %v", got)
+	}
+
+	// A partial last block still counts; -32768 must not overflow.
+	loud := NewAnalyzer(4)
+	loud.Write(pcm(-32768))
+	if got := loud.Peaks(1); got[0] != 100 {
+		t.Errorf("Peaks = %v, want [100]", got)
+	}
+}
+
+func TestWritePNG(t *testing.T) {
+
+	var buf bytes.Buffer
+	fg := color.NRGBA{R: 0x34, G: 0x78, B: 0xF6, A: 0xFF}
+	if err := WritePNG(&buf, []int{0, 100, 50}, 30, 10, fg); err != nil {
+		t.Fatalf("WritePNG: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 30 || b.Dy() != 10 {
+		t.Fatalf("size = %v", b)
+	}
+
+	// The full-height bar spans every row; the silent one only the middle.
+	if _, _, _, alpha := img.At(12, 0).RGBA(); alpha == 0 {
+		t.Errorf("loudest bar should reach the top")
+	}
+	if _, _, _, alpha := img.At(2, 0).RGBA(); alpha != 0 {
+		t.Errorf("silent bar should not reach the top")
+	}
+	if _, _, _, alpha := img.At(2, 4).RGBA(); alpha == 0 {
+		t.Errorf("silent bar should still show a line")
+	}
+}
@@ -0,0 +1,279 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/events"
+	"github.com/mahdi-cpp/upload-service/internal/metrics"
+)
+
+var (
+	ErrInvalidURL = errors.New("callback URL must be an absolute http or https URL")
+	ErrNotDead    = errors.New("only dead deliveries can be retried")
+)
+
+// Types are the events posted to callbacks: processing finished or failed.
+var Types = []events.Type{events.UploadProcessed, events.UploadFailed}
+
+// Options tune delivery. Zero values are not valid; see config.Webhook*.
+type Options struct {
+	Timeout     time.Duration // per attempt
+	MaxAttempts int           // attempts before a delivery is dead
+	MinBackoff  time.Duration // wait after the first failure, doubling after each
+	MaxBackoff  time.Duration
+	Poll        time.Duration // how often due retries are looked for
+	Retention   time.Duration // how long delivered records stay in the log
+}
+
+// Dispatcher turns events into signed HTTP POSTs to the callback URL that
+// resolve returns for them, retrying failures with exponential backoff.
+type Dispatcher struct {
+	store   *Store
+	secret  []byte
+	resolve func(events.Event) string
+	client  *http.Client
+	opts    Options
+	notify  chan struct{}
+	now     func() time.Time
+}
+
+// NewDispatcher posts events for which resolve returns a URL; an empty URL
+// means nobody asked to be called back.
+func NewDispatcher(store *Store, secret []byte, resolve func(events.Event) string, opts Options) *Dispatcher {
+	return &Dispatcher{
+		store:   store,
+		secret:  secret,
+		resolve: resolve,
+		client:  &http.Client{Timeout: opts.Timeout},
+		opts:    opts,
+		notify:  make(chan struct{}, 1),
+		now:     time.Now,
+	}
+}
+
+// Store returns the delivery log.
+func (d *Dispatcher) Store() *Store {
+	return d.store
+}
+
+// ValidURL reports whether raw can be used as a callback URL.
+func ValidURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// Enqueue records a delivery for event if it is a callback type and has a URL.
+func (d *Dispatcher) Enqueue(event events.Event) error {
+	if !isCallbackType(event.Type) {
+		return nil
+	}
+	target := d.resolve(event)
+	if target == "" {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	now := d.now().UTC()
+	added, err := d.store.Add(&Delivery{
+		ID:          event.ID,
+		Type:        event.Type,
+		AssetID:     event.AssetID,
+		Owner:       event.Owner,
+		Namespace:   event.Namespace,
+		URL:         target,
+		Payload:     payload,
+		Status:      StatusPending,
+		NextAttempt: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+	if err != nil {
+		return err
+	}
+	if added {
+		d.wake()
+	}
+	return nil
+}
+
+// Retry gives a dead delivery a fresh set of attempts.
+func (d *Dispatcher) Retry(id uuid.UUID) (*Delivery, error) {
+	delivery, err := d.store.Update(id, func(dl *Delivery) error {
+		if dl.Status != StatusDead {
+			return ErrNotDead
+		}
+		dl.Status = StatusPending
+		dl.Attempts = 0
+		dl.NextAttempt = d.now().UTC()
+		dl.UpdatedAt = dl.NextAttempt
+		return nil
+	})
+	if err == nil {
+		d.wake()
+	}
+	return delivery, err
+}
+
+// Run records events from the channel and delivers due deliveries until ctx
+// is done. Deliveries left pending by a restart are picked up again.
+func (d *Dispatcher) Run(ctx context.Context, incoming <-chan events.Event) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-incoming:
+				if !ok {
+					return
+				}
+				if err := d.Enqueue(event); err != nil {
+					slog.Error("failed to record webhook delivery", "event_id", event.ID, "error", err)
+				}
+			}
+		}
+	}()
+
+	poll := time.NewTicker(d.opts.Poll)
+	defer poll.Stop()
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+
+	for {
+		d.DeliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.notify:
+		case <-poll.C:
+		case <-prune.C:
+			if _, err := d.store.Prune(d.now().Add(-d.opts.Retention)); err != nil {
+				slog.Error("failed to prune webhook deliveries", "error", err)
+			}
+		}
+	}
+}
+
+// DeliverDue attempts every delivery whose next attempt has come.
+func (d *Dispatcher) DeliverDue(ctx context.Context) {
+	due, err := d.store.Due(d.now())
+	if err != nil {
+		slog.Error("failed to list webhook deliveries", "error", err)
+		return
+	}
+	for _, delivery := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		d.attempt(ctx, delivery)
+	}
+}
+
+// attempt posts the delivery once and records the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, delivery *Delivery) {
+	status, err := d.post(ctx, delivery)
+	if ctx.Err() != nil {
+		return // shutting down; the attempt is retried after restart
+	}
+
+	now := d.now().UTC()
+	updated, updateErr := d.store.Update(delivery.ID, func(dl *Delivery) error {
+		dl.Attempts++
+		dl.LastStatus = status
+		dl.UpdatedAt = now
+		switch {
+		case err == nil:
+			dl.Status = StatusDelivered
+			dl.LastError = ""
+			dl.NextAttempt = time.Time{}
+		case dl.Attempts >= d.opts.MaxAttempts:
+			dl.Status = StatusDead
+			dl.LastError = err.Error()
+			dl.NextAttempt = time.Time{}
+		default:
+			dl.LastError = err.Error()
+			dl.NextAttempt = now.Add(d.backoff(dl.Attempts))
+		}
+		return nil
+	})
+	if updateErr != nil {
+		slog.Error("failed to record webhook attempt", "delivery_id", delivery.ID, "error", updateErr)
+		return
+	}
+
+	switch updated.Status {
+	case StatusDelivered:
+		metrics.WebhookDeliveries.WithLabelValues(string(StatusDelivered)).Inc()
+	case StatusDead:
+		metrics.WebhookDeliveries.WithLabelValues(string(StatusDead)).Inc()
+		slog.Error("webhook delivery failed permanently", "delivery_id", updated.ID, "url", updated.URL, "attempts", updated.Attempts, "error", err)
+	default:
+		metrics.WebhookDeliveries.WithLabelValues("retry").Inc()
+		slog.Warn("webhook delivery failed", "delivery_id", updated.ID, "attempt", updated.Attempts, "next_attempt", updated.NextAttempt, "error", err)
+	}
+}
+
+// post sends one signed request and returns the HTTP status, if any.
+func (d *Dispatcher) post(ctx context.Context, delivery *Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(d.secret, d.now(), delivery.Payload))
+	req.Header.Set(EventHeader, string(delivery.Type))
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff is the wait after the given number of failed attempts.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.opts.MinBackoff
+	for i := 1; i < attempts && wait < d.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.opts.MaxBackoff)
+}
+
+func (d *Dispatcher) wake() {
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+}
+
+func isCallbackType(t events.Type) bool {
+	for _, callback := range Types {
+		if t == callback {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/events"
+	bolt "go.etcd.io/bbolt"
+)
+
+var ErrNotFound = errors.New("delivery not found")
+
+var (
+	deliveriesBucket = []byte("deliveries")
+	pendingBucket    = []byte("pending")
+)
+
+// Status tracks a delivery through its retries.
+type Status string
+
+const (
+	StatusPending   Status = "pending"   // waiting for its next attempt
+	StatusDelivered Status = "delivered" // the receiver answered 2xx
+	StatusDead      Status = "dead"      // gave up after the last attempt; see LastError
+)
+
+// Delivery is one event sent to one callback URL. Its ID is the event ID, so
+// an event delivered twice by the outbox is only posted once.
+type Delivery struct {
+	ID          uuid.UUID       `json:"id"`
+	Type        events.Type     `json:"type"`
+	AssetID     uuid.UUID       `json:"assetId"`
+	Owner       string          `json:"owner"`
+	Namespace   string          `json:"namespace"`
+	URL         string          `json:"url"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      Status          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	LastStatus  int             `json:"lastStatus,omitempty"` // HTTP status of the last attempt
+	LastError   string          `json:"lastError,omitempty"`
+	NextAttempt time.Time       `json:"nextAttempt,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// Store keeps deliveries in a bbolt file. Pending deliveries are also
+// indexed by ID so the dispatcher doesn't scan the whole log.
+type Store struct {
+	db *bolt.DB
+}
+
+// OpenStore opens or creates the delivery log at path.
+func OpenStore(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("webhooks: open %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{deliveriesBucket, pendingBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add records a new delivery. It reports false, without error, when a
+// delivery with the same ID already exists.
+func (s *Store) Add(d *Delivery) (bool, error) {
+	added := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(deliveriesBucket).Get(d.ID[:]) != nil {
+			return nil
+		}
+		added = true
+		return put(tx, d)
+	})
+	return added, err
+}
+
+// Get returns the delivery with the given ID.
+func (s *Store) Get(id uuid.UUID) (*Delivery, error) {
+	var d *Delivery
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		d, err = get(tx, id)
+		return err
+	})
+	return d, err
+}
+
+// Update applies fn to the stored delivery and saves the result atomically.
+func (s *Store) Update(id uuid.UUID, fn func(*Delivery) error) (*Delivery, error) {
+	var d *Delivery
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if d, err = get(tx, id); err != nil {
+			return err
+		}
+		if err := fn(d); err != nil {
+			return err
+		}
+		return put(tx, d)
+	})
+	return d, err
+}
+
+// Due returns pending deliveries whose next attempt is at or before now.
+func (s *Store) Due(now time.Time) ([]*Delivery, error) {
+	var due []*Delivery
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(pendingBucket).ForEach(func(k, _ []byte) error {
+			d, err := get(tx, uuid.UUID(k))
+			if err != nil {
+				return err
+			}
+			if !d.NextAttempt.After(now) {
+				due = append(due, d)
+			}
+			return nil
+		})
+	})
+	sort.Slice(due, func(i, j int) bool { return due[i].CreatedAt.Before(due[j].CreatedAt) })
+	return due, err
+}
+
+// Filter selects deliveries for List. Zero values don't filter.
+type Filter struct {
+	Owner   string
+	AssetID uuid.UUID
+	Status  Status
+	Limit   int
+}
+
+// List returns matching deliveries, newest first.
+func (s *Store) List(f Filter) ([]*Delivery, error) {
+	list := []*Delivery{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(deliveriesBucket).ForEach(func(_, v []byte) error {
+			var d Delivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			if (f.Owner == "" || d.Owner == f.Owner) &&
+				(f.AssetID == uuid.Nil || d.AssetID == f.AssetID) &&
+				(f.Status == "" || d.Status == f.Status) {
+				list = append(list, &d)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	if f.Limit > 0 && len(list) > f.Limit {
+		list = list[:f.Limit]
+	}
+	return list, nil
+}
+
+// Prune removes delivered records last updated before cutoff and returns
+// how many were removed. Dead letters are kept until retried.
+func (s *Store) Prune(cutoff time.Time) (int, error) {
+	pruned := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(deliveriesBucket)
+
+		// Collect first: deleting under a cursor skips the following key.
+		var expired [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			var d Delivery
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			if d.Status == StatusDelivered && d.UpdatedAt.Before(cutoff) {
+				expired = append(expired, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		pruned = len(expired)
+		return nil
+	})
+	return pruned, err
+}
+
+func get(tx *bolt.Tx, id uuid.UUID) (*Delivery, error) {
+	data := tx.Bucket(deliveriesBucket).Get(id[:])
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	var d Delivery
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// put saves d and keeps the pending index in step with its status.
+func put(tx *bolt.Tx, d *Delivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(deliveriesBucket).Put(d.ID[:], data); err != nil {
+		return err
+	}
+	if d.Status == StatusPending {
+		return tx.Bucket(pendingBucket).Put(d.ID[:], nil)
+	}
+	return tx.Bucket(pendingBucket).Delete(d.ID[:])
+}
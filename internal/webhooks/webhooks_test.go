@@ -0,0 +1,198 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mahdi-cpp/upload-service/internal/assets"
+	"github.com/mahdi-cpp/upload-service/internal/events"
+)
+
+var secret = []byte("test-secret")
+
+func TestSignAndVerify(t *testing.T) {
+
+	body := []byte(`{"type":"upload.processed"}`)
+	now := time.Now()
+	header := Sign(secret, now, body)
+
+	if err := Verify(secret, header, body, time.Minute, now); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := Verify(secret, header, []byte(`{}`), time.Minute, now); err != ErrInvalidSignature {
+		t.Errorf("tampered body: got %v", err)
+	}
+	if err := Verify([]byte("other"), header, body, time.Minute, now); err != ErrInvalidSignature {
+		t.Errorf("wrong secret: got %v", err)
+	}
+	if err := Verify(secret, header, body, time.Minute, now.Add(2*time.Minute)); err != ErrStaleSignature {
+		t.Errorf("old signature: got %v", err)
+	}
+}
+
+func TestValidURL(t *testing.T) {
+	for raw, valid := range map[string]bool{
+		"https://partner.example/hooks": true,
+		"http://10.0.0.2:8080/cb":       true,
+		"ftp://partner.example/":        false,
+		"/relative":                     false,
+		"https://user:pw@partner/":      false,
+	} {
+		if err := ValidURL(raw); (err == nil) != valid {
+			t.Errorf("ValidURL(%q) = %v", raw, err)
+		}
+	}
+}
+
+// receiver answers with the queued status codes, then 200.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newDispatcher(t *testing.T, url string) (*Dispatcher, *time.Time) {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "webhooks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	now := time.Now()
+	d := NewDispatcher(store, secret, func(events.Event) string { return url }, Options{
+		Timeout:     time.Second,
+		MaxAttempts: 3,
+		MinBackoff:  time.Minute,
+		MaxBackoff:  time.Hour,
+		Poll:        time.Second,
+		Retention:   time.Hour,
+	})
+	d.now = func() time.Time { return now }
+	return d, &now
+}
+
+func processedEvent() events.Event {
+	return events.New(events.UploadProcessed, &assets.Asset{
+		ID:        uuid.New(),
+		Owner:     "018f3a8b-1b32-729a-f7e5-5467c1b2d3e4",
+		Namespace: "com.iris.photos",
+		Status:    assets.StatusReady,
+	})
+}
+
+func TestDeliveryRetriesWithBackoff(t *testing.T) {
+
+	rcv := &receiver{statuses: []int{http.StatusServiceUnavailable}}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	d, now := newDispatcher(t, server.URL)
+	ctx := context.Background()
+
+	event := processedEvent()
+	if err := d.Enqueue(event); err != nil {
+		t.Fatal(err)
+	}
+	// The outbox may hand over the same event twice; it is posted once.
+	if err := d.Enqueue(event); err != nil {
+		t.Fatal(err)
+	}
+	// Only processing outcomes are posted.
+	if err := d.Enqueue(events.New(events.AssetDeleted, &assets.Asset{ID: uuid.New()})); err != nil {
+		t.Fatal(err)
+	}
+
+	d.DeliverDue(ctx)
+	delivery, err := d.store.Get(event.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != StatusPending || delivery.Attempts != 1 || delivery.LastStatus != 503 || !delivery.NextAttempt.Equal(now.UTC().Add(time.Minute)) {
+		t.Fatalf("unexpected delivery after a failure: %+v", delivery)
+	}
+
+	// Not due yet.
+	d.DeliverDue(ctx)
+	if len(rcv.requests) != 1 {
+		t.Fatalf("retried before the backoff passed")
+	}
+
+	*now = now.Add(time.Minute)
+	d.DeliverDue(ctx)
+	if delivery, _ = d.store.Get(event.ID); delivery.Status != StatusDelivered || delivery.Attempts != 2 {
+		t.Fatalf("unexpected delivery after success: %+v", delivery)
+	}
+
+	req := rcv.requests[1]
+	if req.Header.Get(EventHeader) != string(events.UploadProcessed) || req.Header.Get(DeliveryHeader) != event.ID.String() {
+		t.Errorf("unexpected headers %v", req.Header)
+	}
+	if err := Verify(secret, req.Header.Get(SignatureHeader), rcv.bodies[1], time.Minute, *now); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+	if len(rcv.requests) != 2 {
+		t.Errorf("expected 2 requests, got %d", len(rcv.requests))
+	}
+}
+
+func TestDeadLetterAndRetry(t *testing.T) {
+
+	rcv := &receiver{statuses: []int{500, 500, 500}}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	d, now := newDispatcher(t, server.URL)
+	event := processedEvent()
+	if err := d.Enqueue(event); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		d.DeliverDue(context.Background())
+		*now = now.Add(time.Hour)
+	}
+
+	dead, err := d.store.List(Filter{Status: StatusDead})
+	if err != nil || len(dead) != 1 || dead[0].Attempts != 3 || dead[0].LastError == "" {
+		t.Fatalf("expected one dead letter, got %+v (%v)", dead, err)
+	}
+	if due, _ := d.store.Due(*now); len(due) != 0 {
+		t.Errorf("dead deliveries must not be retried automatically")
+	}
+
+	if _, err := d.Retry(event.ID); err != nil {
+		t.Fatal(err)
+	}
+	d.DeliverDue(context.Background())
+	if delivery, _ := d.store.Get(event.ID); delivery.Status != StatusDelivered {
+		t.Errorf("retried delivery not delivered: %+v", delivery)
+	}
+	if _, err := d.Retry(event.ID); err != ErrNotDead {
+		t.Errorf("retrying a delivered record: got %v", err)
+	}
+
+	if pruned, err := d.store.Prune(now.Add(time.Hour)); err != nil || pruned != 1 {
+		t.Errorf("Prune = %d, %v", pruned, err)
+	}
+}